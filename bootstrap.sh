@@ -133,7 +133,7 @@ deploy_bootstrap_nats() {
             # Check if we should use embedded NATS for development
             if [ "$BOOTSTRAP_MODE" = "dev" ]; then
                 log "Starting embedded NATS for development..."
-                go run cmd/nats-bootstrap/main.go &
+                go run ./cmd/nats-bootstrap &
                 NATS_PID=$!
                 echo $NATS_PID > .nats-bootstrap.pid
                 
@@ -189,7 +189,7 @@ deploy_nats_controller() {
     # Build controller if needed
     if [ ! -f "bin/nats-controller" ]; then
         log "Building NATS controller..."
-        go build -o bin/nats-controller cmd/nats-controller/main.go
+        go build -o bin/nats-controller ./cmd/nats-controller
     fi
     
     # Start controller based on deployment type
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the data templates are rendered with. Everything but GitHubOrg
+// comes from the -data file; unset fields keep the values the templates
+// were written with, so output without a data file does not change.
+type Config struct {
+	GitHubOrg     string                 `yaml:"org"`
+	DefaultBranch string                 `yaml:"default_branch"`
+	GoVersion     string                 `yaml:"go_version"`
+	Maintainers   []string               `yaml:"maintainers"` // GitHub users owning everything
+	Teams         []Team                 `yaml:"teams"`
+	Ecosystems    []Ecosystem            `yaml:"ecosystems"`
+	Labels        map[string][]string    `yaml:"labels"`        // issue form name to the labels it applies
+	Custom        map[string]interface{} `yaml:"custom"`        // free-form values for templates
+	SetupRef      string                 `yaml:"setup_ref"`     // ref of this repository workflows build github-setup from
+	SetupCommand  string                 `yaml:"setup_command"` // how workflows run github-setup
+	DataFile      string                 `yaml:"-"`             // -data path, for commands in generated workflows
+	Repo          string                 `yaml:"-"`             // repository being rendered with -repos, "" otherwise
+}
+
+// Team is an org team owning everything, or only Paths when given
+type Team struct {
+	Name  string   `yaml:"name"` // team slug, without the org
+	Paths []string `yaml:"paths"`
+}
+
+// Ecosystem is a Dependabot package ecosystem to keep up to date
+type Ecosystem struct {
+	Name      string `yaml:"name"` // package-ecosystem, e.g. gomod
+	Directory string `yaml:"directory"`
+	Interval  string `yaml:"interval"`
+	Prefix    string `yaml:"prefix"` // commit message prefix
+}
+
+// defaultEcosystems are the ecosystems dependabot.yml has always listed
+var defaultEcosystems = []Ecosystem{
+	{Name: "github-actions", Prefix: "ci"},
+	{Name: "gomod", Prefix: "deps"},
+	{Name: "docker", Prefix: "docker"},
+}
+
+// defaultLabels are the labels the issue forms have always applied
+var defaultLabels = map[string][]string{
+	"bug_report":      {"bug", "triage"},
+	"feature_request": {"enhancement"},
+}
+
+// ecosystemTitles are the comments dependabot.yml uses for common
+// ecosystems; others are named as they are
+var ecosystemTitles = map[string]string{
+	"bundler":        "Ruby gems",
+	"cargo":          "Rust crates",
+	"composer":       "PHP packages",
+	"docker":         "Docker",
+	"github-actions": "GitHub Actions",
+	"gomod":          "Go modules",
+	"gradle":         "Gradle",
+	"maven":          "Maven",
+	"npm":            "npm packages",
+	"nuget":          "NuGet packages",
+	"pip":            "Python packages",
+	"terraform":      "Terraform",
+}
+
+// Schema checks on names, matching what GitHub and Dependabot accept
+var (
+	loginPattern     = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9]|-[A-Za-z0-9])*$`)
+	teamPattern      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	branchPattern    = regexp.MustCompile(`^[^\s~^:?*\[\\]+$`)
+	goVersionPattern = regexp.MustCompile(`^\d+\.\d+(?:\.\d+)?$`)
+	intervals        = map[string]bool{"daily": true, "weekly": true, "monthly": true}
+)
+
+// loadConfig reads YAML or JSON data files, each merged on top of the ones
+// before it; empty paths are skipped and no files give the defaults
+func loadConfig(paths ...string) (*Config, error) {
+	merged := map[string]interface{}{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		layer, err := readLayer(path)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeLayers(merged, layer)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge data files: %w", err)
+	}
+	return parseConfig(data)
+}
+
+// readLayer reads one data file, checking it on its own first so errors
+// point at the file and line that caused them
+func readLayer(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	if _, err := parseConfig(data); err != nil {
+		return nil, fmt.Errorf("invalid data file %s: %w", path, err)
+	}
+
+	layer := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &layer); err != nil {
+		return nil, fmt.Errorf("invalid data file %s: %w", path, err)
+	}
+	return layer, nil
+}
+
+// mergeLayers merges overlay into base: nested maps are merged key by key,
+// anything else (including lists such as teams) is replaced
+func mergeLayers(base, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		baseMap, baseIsMap := merged[key].(map[string]interface{})
+		overlayMap, overlayIsMap := value.(map[string]interface{})
+		if baseIsMap && overlayIsMap {
+			merged[key] = mergeLayers(baseMap, overlayMap)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// parseConfig parses a YAML or JSON data file, rejecting unknown fields,
+// and fills in defaults
+func parseConfig(data []byte) (*Config, error) {
+	var config Config
+	if len(strings.TrimSpace(string(data))) > 0 {
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	config.setDefaults()
+	return &config, nil
+}
+
+// setDefaults fills in what the data file left out
+func (c *Config) setDefaults() {
+	if c.DefaultBranch == "" {
+		c.DefaultBranch = "main"
+	}
+	if c.GoVersion == "" {
+		c.GoVersion = "1.21"
+	}
+	if c.SetupCommand == "" {
+		c.SetupCommand = "go run ./cmd/github-setup"
+		if c.SetupRef != "" {
+			c.SetupCommand = "$RUNNER_TEMP/github-setup"
+		}
+	}
+	if c.Ecosystems == nil {
+		c.Ecosystems = append([]Ecosystem(nil), defaultEcosystems...)
+	}
+	for i := range c.Ecosystems {
+		if c.Ecosystems[i].Directory == "" {
+			c.Ecosystems[i].Directory = "/"
+		}
+		if c.Ecosystems[i].Interval == "" {
+			c.Ecosystems[i].Interval = "weekly"
+		}
+		if c.Ecosystems[i].Prefix == "" {
+			c.Ecosystems[i].Prefix = "deps"
+		}
+	}
+
+	labels := make(map[string][]string, len(defaultLabels)+len(c.Labels))
+	for form, names := range defaultLabels {
+		labels[form] = names
+	}
+	for form, names := range c.Labels {
+		labels[form] = names
+	}
+	c.Labels = labels
+
+	if c.Custom == nil {
+		c.Custom = map[string]interface{}{}
+	}
+}
+
+// Validate checks the data against the schema GitHub and Dependabot impose
+func (c *Config) Validate() error {
+	if c.GitHubOrg == "" {
+		return fmt.Errorf("GitHub organization name is required (-org flag or org in the data file)")
+	}
+	if !loginPattern.MatchString(c.GitHubOrg) {
+		return fmt.Errorf("invalid organization name %q", c.GitHubOrg)
+	}
+	if !branchPattern.MatchString(c.DefaultBranch) {
+		return fmt.Errorf("invalid default_branch %q", c.DefaultBranch)
+	}
+	if !goVersionPattern.MatchString(c.GoVersion) {
+		return fmt.Errorf("invalid go_version %q: expected e.g. 1.22 or 1.22.3", c.GoVersion)
+	}
+	if c.SetupRef != "" && !branchPattern.MatchString(c.SetupRef) {
+		return fmt.Errorf("invalid setup_ref %q: expected a branch, tag or commit", c.SetupRef)
+	}
+	if strings.ContainsAny(c.SetupCommand, "\r\n") {
+		return fmt.Errorf("invalid setup_command %q: must be a single line", c.SetupCommand)
+	}
+
+	for _, login := range c.Maintainers {
+		if !loginPattern.MatchString(login) {
+			return fmt.Errorf("invalid maintainer %q: expected a GitHub username without @", login)
+		}
+	}
+
+	teams := make(map[string]bool)
+	for _, team := range c.Teams {
+		if !teamPattern.MatchString(team.Name) {
+			return fmt.Errorf("invalid team %q: expected a team slug without the org", team.Name)
+		}
+		if teams[team.Name] {
+			return fmt.Errorf("team %s declared twice", team.Name)
+		}
+		teams[team.Name] = true
+		for _, path := range team.Paths {
+			if path == "" || strings.ContainsAny(path, " \t\n") {
+				return fmt.Errorf("team %s: invalid path %q", team.Name, path)
+			}
+		}
+	}
+
+	ecosystems := make(map[string]bool)
+	for _, eco := range c.Ecosystems {
+		if !dependabotEcosystems[eco.Name] {
+			return fmt.Errorf("unknown ecosystem %q: expected one of %s", eco.Name, strings.Join(knownEcosystems(), ", "))
+		}
+		key := eco.Name + " " + eco.Directory
+		if ecosystems[key] {
+			return fmt.Errorf("ecosystem %s declared twice for %s", eco.Name, eco.Directory)
+		}
+		ecosystems[key] = true
+		if !strings.HasPrefix(eco.Directory, "/") {
+			return fmt.Errorf("ecosystem %s: directory %q must start with /", eco.Name, eco.Directory)
+		}
+		if !intervals[eco.Interval] {
+			return fmt.Errorf("ecosystem %s: invalid interval %q: expected daily, weekly or monthly", eco.Name, eco.Interval)
+		}
+	}
+
+	for form, names := range c.Labels {
+		for _, name := range names {
+			if strings.TrimSpace(name) == "" {
+				return fmt.Errorf("labels %s: empty label", form)
+			}
+		}
+	}
+
+	return nil
+}
+
+// knownEcosystems lists the ecosystems Validate accepts
+func knownEcosystems() []string {
+	names := make([]string, 0, len(dependabotEcosystems))
+	for name := range dependabotEcosystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Owners is the CODEOWNERS owner list for everything: the maintainers and
+// teams without paths, or the whole org when there are none
+func (c Config) Owners() string {
+	var owners []string
+	for _, login := range c.Maintainers {
+		owners = append(owners, "@"+login)
+	}
+	for _, team := range c.Teams {
+		if len(team.Paths) == 0 {
+			owners = append(owners, team.Handle(c.GitHubOrg))
+		}
+	}
+	if len(owners) == 0 {
+		return "@" + c.GitHubOrg
+	}
+	return strings.Join(owners, " ")
+}
+
+// LabelList formats the labels of an issue form as a YAML flow sequence
+func (c Config) LabelList(form string) string {
+	quoted := make([]string, len(c.Labels[form]))
+	for i, name := range c.Labels[form] {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// Handle is the CODEOWNERS form of the team, @org/team
+func (t Team) Handle(org string) string {
+	return "@" + org + "/" + t.Name
+}
+
+// Title is the comment naming the ecosystem in dependabot.yml
+func (e Ecosystem) Title() string {
+	if title, ok := ecosystemTitles[e.Name]; ok {
+		return title
+	}
+	return e.Name
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeLayers(t *testing.T) {
+	type m = map[string]interface{}
+
+	tests := []struct {
+		name          string
+		base, overlay m
+		want          m
+	}{
+		{"empty overlay", m{"org": "acme"}, m{}, m{"org": "acme"}},
+		{"empty base", m{}, m{"org": "acme"}, m{"org": "acme"}},
+		{"disjoint keys", m{"org": "acme"}, m{"go_version": "1.22"}, m{"org": "acme", "go_version": "1.22"}},
+		{"scalar replaced", m{"go_version": "1.21"}, m{"go_version": "1.22"}, m{"go_version": "1.22"}},
+		{"maps merged key by key",
+			m{"custom": m{"slack": "#dev", "nested": m{"a": 1, "b": 2}}},
+			m{"custom": m{"owner": "ops", "nested": m{"b": 3}}},
+			m{"custom": m{"slack": "#dev", "owner": "ops", "nested": m{"a": 1, "b": 3}}}},
+		{"lists replaced",
+			m{"maintainers": []interface{}{"alice", "bob"}},
+			m{"maintainers": []interface{}{"carol"}},
+			m{"maintainers": []interface{}{"carol"}}},
+		{"map replaced by scalar", m{"custom": m{"a": 1}}, m{"custom": "none"}, m{"custom": "none"}},
+		{"scalar replaced by map", m{"custom": "none"}, m{"custom": m{"a": 1}}, m{"custom": m{"a": 1}}},
+		{"null overlay value", m{"go_version": "1.21"}, m{"go_version": nil}, m{"go_version": nil}},
+	}
+	for _, tt := range tests {
+		base := mergeLayers(m{}, tt.base) // a copy, to check tt.base is left alone
+		if got := mergeLayers(tt.base, tt.overlay); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeLayers = %v, want %v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(tt.base, base) {
+			t.Errorf("%s: mergeLayers modified base: %v, was %v", tt.name, tt.base, base)
+		}
+	}
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	org := write("org.yaml", `org: acme
+go_version: "1.21"
+teams:
+  - name: owners
+  - name: docs
+    paths: [/docs/]
+custom:
+  slack: "#dev"
+  deploy: {region: eu, replicas: 2}
+`)
+	repo := write("widgets.yaml", `go_version: "1.22"
+teams:
+  - name: widgets
+custom:
+  deploy: {replicas: 3}
+`)
+
+	config, err := loadConfig(org, "", repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.GitHubOrg != "acme" || config.GoVersion != "1.22" || config.DefaultBranch != "main" {
+		t.Errorf("org %q, go %q, branch %q; want acme, 1.22, main", config.GitHubOrg, config.GoVersion, config.DefaultBranch)
+	}
+	if len(config.Teams) != 1 || config.Teams[0].Name != "widgets" {
+		t.Errorf("teams = %+v, want the overlay's list only", config.Teams)
+	}
+	want := map[string]interface{}{"slack": "#dev", "deploy": map[string]interface{}{"region": "eu", "replicas": 3}}
+	if !reflect.DeepEqual(config.Custom, want) {
+		t.Errorf("custom = %v, want %v", config.Custom, want)
+	}
+
+	// Errors point at the layer that caused them
+	bad := write("bad.yaml", "go_versoin: \"1.22\"\n")
+	if _, err := loadConfig(org, bad); err == nil || !strings.Contains(err.Error(), bad) {
+		t.Errorf("loadConfig with an unknown key = %v, want an error naming %s", err, bad)
+	}
+}
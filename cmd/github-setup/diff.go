@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffOp is one line of a line diff: ' ' kept, '-' removed or '+' added
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns a unified diff turning a into b, or "" when they are equal
+func unifiedDiff(fromName, toName string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are close together
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops); i++ {
+			if ops[i].kind == ' ' {
+				continue
+			}
+			if i-last > 2*diffContext {
+				break
+			}
+			last = i
+		}
+
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(ops))
+		writeHunk(&out, ops, from, to)
+		start = to
+	}
+
+	return out.String()
+}
+
+// writeHunk writes ops[from:to] as one hunk with its @@ header
+func writeHunk(out *strings.Builder, ops []diffOp, from, to int) {
+	aStart, bStart := 0, 0
+	for _, op := range ops[:from] {
+		if op.kind != '+' {
+			aStart++
+		}
+		if op.kind != '-' {
+			bStart++
+		}
+	}
+
+	aLen, bLen := 0, 0
+	for _, op := range ops[from:to] {
+		if op.kind != '+' {
+			aLen++
+		}
+		if op.kind != '-' {
+			bLen++
+		}
+	}
+	if aLen > 0 {
+		aStart++
+	}
+	if bLen > 0 {
+		bStart++
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+	for _, op := range ops[from:to] {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a hunk range, omitting the length when it is 1
+func hunkRange(start, length int) string {
+	if length == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// splitLines splits data into lines, each keeping its trailing newline
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a minimal line diff of a and b from their longest common
+// subsequence. Generated files are small, so the quadratic table is fine.
+func diffLines(a, b []string) []diffOp {
+	// Common prefix and suffix need no table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			ops = append(ops, diffOp{' ', x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', x[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		ops = append(ops, diffOp{'-', x[i]})
+	}
+	for ; j < len(y); j++ {
+		ops = append(ops, diffOp{'+', y[j]})
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
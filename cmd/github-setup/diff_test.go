@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns lines 1 to n, with some lines replaced
+func numbered(n int, replace map[int]string) []byte {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := replace[i]; ok {
+			fmt.Fprintln(&b, line)
+			continue
+		}
+		fmt.Fprintln(&b, i)
+	}
+	return []byte(b.String())
+}
+
+// hunks joins expected diff lines, as `diff -u` prints them after the header
+func hunks(lines ...string) string {
+	return "--- a\n+++ b\n" + strings.Join(lines, "\n") + "\n"
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []byte
+		want string
+	}{
+		{"equal", []byte("x\n"), []byte("x\n"), ""},
+		{"both empty", nil, nil, ""},
+		{"new file", nil, []byte("one\ntwo\n"), hunks(
+			"@@ -0,0 +1,2 @@", "+one", "+two")},
+		{"removed file", []byte("one\ntwo\n"), nil, hunks(
+			"@@ -1,2 +0,0 @@", "-one", "-two")},
+		{"one change with context", numbered(10, nil), numbered(10, map[int]string{5: "five"}), hunks(
+			"@@ -2,7 +2,7 @@", " 2", " 3", " 4", "-5", "+five", " 6", " 7", " 8")},
+		{"changes far apart", numbered(20, nil), numbered(20, map[int]string{2: "two", 18: "eighteen"}), hunks(
+			"@@ -1,5 +1,5 @@", " 1", "-2", "+two", " 3", " 4", " 5",
+			"@@ -15,6 +15,6 @@", " 15", " 16", " 17", "-18", "+eighteen", " 19", " 20")},
+		{"changes close together", numbered(12, nil), numbered(12, map[int]string{3: "three", 9: "nine"}), hunks(
+			"@@ -1,12 +1,12 @@", " 1", " 2", "-3", "+three", " 4", " 5", " 6", " 7", " 8", "-9", "+nine", " 10", " 11", " 12")},
+		{"insertion", numbered(3, nil), []byte("1\n2\nnew\n3\n"), hunks(
+			"@@ -1,3 +1,4 @@", " 1", " 2", "+new", " 3")},
+		{"no newline at end", []byte("a\nb"), []byte("a\nc"), hunks(
+			"@@ -1,2 +1,2 @@", " a", "-b", `\ No newline at end of file`, "+c", `\ No newline at end of file`)},
+		{"newline added at end", []byte("a"), []byte("a\n"), hunks(
+			"@@ -1 +1 @@", "-a", `\ No newline at end of file`, "+a")},
+	}
+	for _, tt := range tests {
+		if got := unifiedDiff("a", "b", tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: unifiedDiff =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
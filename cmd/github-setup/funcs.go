@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// partialsDir holds templates every file can use with {{template}} or include;
+// it is not rendered itself
+const partialsDir = "_partials"
+
+// templateFuncs returns the functions available to templates. include is
+// bound to the template set by bindInclude once the set is parsed.
+func templateFuncs(allowEnv []string) template.FuncMap {
+	allowed := make(map[string]bool, len(allowEnv))
+	for _, name := range allowEnv {
+		allowed[name] = true
+	}
+
+	return template.FuncMap{
+		// Strings
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+		"title": titleCase,
+		"camel": camelCase,
+		"snake": func(s string) string { return strings.ToLower(strings.Join(words(s), "_")) },
+		"kebab": func(s string) string { return strings.ToLower(strings.Join(words(s), "-")) },
+		"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+
+		// Layout
+		"indent":  indent,
+		"nindent": func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"toYaml":  toYaml,
+
+		// Values
+		"default": defaultValue,
+		"list":    func(items ...interface{}) []interface{} { return items },
+		"dict":    dict,
+		"env": func(name string) (string, error) {
+			if !allowed[name] {
+				return "", fmt.Errorf("environment variable %s is not allowed (add it to -allow-env)", name)
+			}
+			return os.Getenv(name), nil
+		},
+
+		// GitHub Actions expressions, e.g. {{gha "secrets.GITHUB_TOKEN"}} renders ${{ secrets.GITHUB_TOKEN }}
+		"gha": func(expr string) string { return "${{ " + expr + " }}" },
+
+		"include": func(name string, data interface{}) (string, error) {
+			return "", fmt.Errorf("include %s called before the templates were parsed", name)
+		},
+	}
+}
+
+// bindInclude makes include render templates from set
+func bindInclude(set *template.Template) {
+	set.Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			if err := set.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+	})
+}
+
+// indent prefixes every non-empty line of s with spaces
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toYaml renders v as YAML indented by two spaces, without the trailing newline
+func toYaml(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// defaultValue returns value, or fallback when value is missing or empty;
+// it takes the fallback first so it reads well in pipelines: {{.X | default "y"}}
+func defaultValue(fallback interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || value[0] == nil {
+		return fallback
+	}
+	v := reflect.ValueOf(value[0])
+	if v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0) {
+		return fallback
+	}
+	return value[0]
+}
+
+// dict builds a map from key, value pairs, e.g. to pass several values to a partial
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict needs key, value pairs")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// words splits s into words at separators and case changes, keeping
+// acronyms together: "HTTPServer fooBar" is HTTP, Server, foo, Bar
+func words(s string) []string {
+	var out []string
+	var word []rune
+	prev := rune(0)
+	runes := []rune(s)
+	for i, r := range runes {
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			if len(word) > 0 {
+				out = append(out, string(word))
+				word = nil
+			}
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) && len(word) > 0:
+			out = append(out, string(word))
+			word = []rune{r}
+		case unicode.IsUpper(r) && unicode.IsUpper(prev) && unicode.IsLower(next) && len(word) > 0:
+			out = append(out, string(word))
+			word = []rune{r}
+		default:
+			word = append(word, r)
+		}
+		prev = r
+	}
+	if len(word) > 0 {
+		out = append(out, string(word))
+	}
+	return out
+}
+
+// titleCase capitalises the first letter of every word, keeping separators
+func titleCase(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// camelCase joins the words of s as camelCase
+func camelCase(s string) string {
+	var b strings.Builder
+	for i, word := range words(s) {
+		word = strings.ToLower(word)
+		if i > 0 {
+			word = titleCase(word)
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
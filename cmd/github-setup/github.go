@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultGitHubAPIURL is used unless -api-url or GITHUB_API_URL says otherwise
+const defaultGitHubAPIURL = "https://api.github.com"
+
+// errNotFound is returned for 404 responses
+var errNotFound = errors.New("not found")
+
+// githubClient makes the GitHub REST API calls sync needs
+type githubClient struct {
+	apiURL string
+	token  string
+	http   *http.Client
+}
+
+// newGitHubClient creates a client for apiURL authenticating with token
+func newGitHubClient(apiURL, token string) *githubClient {
+	return &githubClient{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		token:  token,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends a JSON request to the GitHub API and decodes a JSON response into out
+func (g *githubClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, g.apiURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, path, errNotFound)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// treeEntry is a file in a git tree
+type treeEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+// pullRequest is the part of a pull request sync reports
+type pullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// DefaultBranch returns the default branch of a repository
+func (g *githubClient) DefaultBranch(ctx context.Context, owner, repo string) (string, error) {
+	var out struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s", owner, repo), nil, &out); err != nil {
+		return "", err
+	}
+	return out.DefaultBranch, nil
+}
+
+// BranchSHA returns the commit a branch points at, or errNotFound
+func (g *githubClient) BranchSHA(ctx context.Context, owner, repo, branch string) (string, error) {
+	var out struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	path := fmt.Sprintf("/repos/%s/%s/git/ref/heads/%s", owner, repo, url.PathEscape(branch))
+	if err := g.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return "", err
+	}
+	return out.Object.SHA, nil
+}
+
+// Tree returns the tree SHA of a commit and the blob SHA of every file in it
+func (g *githubClient) Tree(ctx context.Context, owner, repo, commit string) (string, map[string]string, error) {
+	var out struct {
+		SHA       string      `json:"sha"`
+		Tree      []treeEntry `json:"tree"`
+		Truncated bool        `json:"truncated"`
+	}
+	path := fmt.Sprintf("/repos/%s/%s/git/trees/%s?recursive=1", owner, repo, commit)
+	if err := g.do(ctx, http.MethodGet, path, nil, &out); err != nil {
+		return "", nil, err
+	}
+	if out.Truncated {
+		return "", nil, fmt.Errorf("tree of %s/%s is too large to list", owner, repo)
+	}
+
+	blobs := make(map[string]string, len(out.Tree))
+	for _, entry := range out.Tree {
+		if entry.Type == "blob" {
+			blobs[entry.Path] = entry.SHA
+		}
+	}
+	return out.SHA, blobs, nil
+}
+
+// Blob returns the content of a blob
+func (g *githubClient) Blob(ctx context.Context, owner, repo, sha string) ([]byte, error) {
+	var out struct {
+		Content  string `json:"content"`
+		Encoding string `json:"encoding"`
+	}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/git/blobs/%s", owner, repo, sha), nil, &out); err != nil {
+		return nil, err
+	}
+	if out.Encoding != "base64" {
+		return []byte(out.Content), nil
+	}
+	return base64.StdEncoding.DecodeString(strings.ReplaceAll(out.Content, "\n", ""))
+}
+
+// CreateCommit commits files and deletions on top of parent and returns the
+// new commit SHA
+func (g *githubClient) CreateCommit(ctx context.Context, owner, repo, parent, baseTree, message string, files map[string][]byte, deletes []string) (string, error) {
+	entries := make([]map[string]interface{}, 0, len(files)+len(deletes))
+	for path, content := range files {
+		entries = append(entries, map[string]interface{}{"path": path, "mode": "100644", "type": "blob", "content": string(content)})
+	}
+	for _, path := range deletes {
+		entries = append(entries, map[string]interface{}{"path": path, "mode": "100644", "type": "blob", "sha": nil})
+	}
+
+	var tree struct {
+		SHA string `json:"sha"`
+	}
+	body := map[string]interface{}{"base_tree": baseTree, "tree": entries}
+	if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/git/trees", owner, repo), body, &tree); err != nil {
+		return "", err
+	}
+
+	var commit struct {
+		SHA string `json:"sha"`
+	}
+	body = map[string]interface{}{"message": message, "tree": tree.SHA, "parents": []string{parent}}
+	if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/git/commits", owner, repo), body, &commit); err != nil {
+		return "", err
+	}
+	return commit.SHA, nil
+}
+
+// SetBranch points branch at sha, creating it or force-updating it
+func (g *githubClient) SetBranch(ctx context.Context, owner, repo, branch, sha string, exists bool) error {
+	if exists {
+		path := fmt.Sprintf("/repos/%s/%s/git/refs/heads/%s", owner, repo, url.PathEscape(branch))
+		return g.do(ctx, http.MethodPatch, path, map[string]interface{}{"sha": sha, "force": true}, nil)
+	}
+	body := map[string]interface{}{"ref": "refs/heads/" + branch, "sha": sha}
+	return g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/git/refs", owner, repo), body, nil)
+}
+
+// OpenPullRequest returns the open pull request from branch, or nil
+func (g *githubClient) OpenPullRequest(ctx context.Context, owner, repo, branch string) (*pullRequest, error) {
+	var out []pullRequest
+	query := url.Values{"head": {owner + ":" + branch}, "state": {"open"}}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/%s/pulls?%s", owner, repo, query.Encode()), nil, &out); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return &out[0], nil
+}
+
+// CreatePullRequest opens a pull request from head into base
+func (g *githubClient) CreatePullRequest(ctx context.Context, owner, repo, head, base, title, body string) (*pullRequest, error) {
+	var out pullRequest
+	req := map[string]interface{}{"title": title, "head": head, "base": base, "body": body}
+	if err := g.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/%s/pulls", owner, repo), req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// blobSHA is the git object ID of content, as listed in trees
+func blobSHA(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
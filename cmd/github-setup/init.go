@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runInit implements the "init" subcommand, exporting the built-in templates
+// so they can be customized
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := fs.String("dir", "templates", "Directory to export the built-in templates to")
+	force := fs.Bool("force", false, "Overwrite templates in -dir that differ from the built-in ones")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: github-setup init [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Exports the built-in templates for customization. github-setup renders the\n")
+		fmt.Fprintf(fs.Output(), "templates directory over the built-in ones, so files can also be deleted from it\n")
+		fmt.Fprintf(fs.Output(), "to keep the built-in version; pass -no-defaults to render only the directory.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	templates, err := templateFiles([]templateLayer{builtinLayer()})
+	if err != nil {
+		return err
+	}
+
+	// Check everything before writing anything
+	var pending []templateFile
+	for _, t := range templates {
+		outPath := filepath.Join(*dir, filepath.FromSlash(t.Rel))
+		content, err := t.ReadFile()
+		if err != nil {
+			return fmt.Errorf("failed to read template %s: %w", t.Name, err)
+		}
+		current, err := os.ReadFile(outPath)
+		switch {
+		case os.IsNotExist(err):
+			pending = append(pending, t)
+		case err != nil:
+			return fmt.Errorf("failed to read %s: %w", outPath, err)
+		case bytes.Equal(current, content):
+		case *force:
+			pending = append(pending, t)
+		default:
+			return fmt.Errorf("refusing to overwrite %s: it differs from %s (use -force to replace it)", outPath, t.Name)
+		}
+	}
+
+	for _, t := range pending {
+		outPath := filepath.Join(*dir, filepath.FromSlash(t.Rel))
+		content, err := t.ReadFile()
+		if err != nil {
+			return fmt.Errorf("failed to read template %s: %w", t.Name, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(outPath), err)
+		}
+		if err := os.WriteFile(outPath, content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outPath, err)
+		}
+		fmt.Printf("  → %s\n", outPath)
+	}
+
+	if len(pending) == 0 {
+		fmt.Printf("✅ Templates in %s already match the built-in ones.\n", *dir)
+		return nil
+	}
+	fmt.Printf("✅ Exported %d template(s) to %s. Edit them and run github-setup -templates %s\n", len(pending), *dir, *dir)
+	return nil
+}
@@ -5,22 +5,34 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
-	"text/template"
+	"strings"
 )
 
 const version = "1.0.0"
 
-type Config struct {
-	GitHubOrg string
-}
-
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "sync" {
+		if err := runSync(os.Args[2:]); err != nil {
+			log.Fatalf("Sync failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInit(os.Args[2:]); err != nil {
+			log.Fatalf("Init failed: %v", err)
+		}
+		return
+	}
+
 	org := flag.String("org", "", "GitHub organization name")
-	templateDir := flag.String("templates", "templates", "Template directory")
 	outputDir := flag.String("output", ".github", "Output directory")
 	versionFlag := flag.Bool("version", false, "Show version and exit")
 	verbose := flag.Bool("verbose", false, "Verbose output")
+	dataFile := flag.String("data", "", "YAML or JSON data file with teams, maintainers, ecosystems, labels and custom values")
+	reposDir := flag.String("repos", "", "Directory of per-repository overlays (<name>.yaml) merged over -data; renders one tree per repository under -output (default: "+defaultReposOutput+")")
+	render := addRenderFlags(flag.CommandLine)
+	clean := flag.Bool("clean", false, "Remove the files listed in the output directory's manifest instead of rendering (edited files are kept)")
+	check := flag.Bool("check", false, "Render in memory, print a unified diff of out-of-date files and exit 1 if any")
 	flag.Parse()
 
 	if *versionFlag {
@@ -28,76 +40,162 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *org == "" {
-		log.Fatal("GitHub organization name is required (-org flag)")
-	}
-
-	config := Config{GitHubOrg: *org}
-
-	if *verbose {
-		fmt.Printf("Processing templates for organization: %s\n", *org)
-		fmt.Printf("Template directory: %s\n", *templateDir)
-		fmt.Printf("Output directory: %s\n", *outputDir)
+	targets := []target{{OutputDir: *outputDir}}
+	if *reposDir != "" {
+		reposOutput := defaultReposOutput
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "output" {
+				reposOutput = *outputDir
+			}
+		})
+
+		var err error
+		targets, err = loadRepoTargets(*dataFile, *reposDir, reposOutput)
+		if err != nil {
+			log.Fatal(err)
+		}
 	} else {
-		fmt.Printf("Processing templates for organization: %s\n", *org)
+		config, err := loadConfig(*dataFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		targets[0].Config = config
 	}
 
-	err := filepath.Walk(*templateDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	if err := prepareTargets(targets, *org, *dataFile); err != nil {
+		log.Fatal(err)
+	}
+	opts, err := render.options(*verbose)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var layers []templateLayer
+	var templates []templateFile
+	if !*clean {
+		if layers, err = render.templateLayers(*check); err != nil {
+			log.Fatal(err)
+		}
+		if templates, err = templateFiles(layers); err != nil {
+			log.Fatal(err)
 		}
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
+	if *verbose {
+		if *dataFile != "" {
+			fmt.Printf("Data file: %s\n", *dataFile)
+		}
+		if *reposDir != "" {
+			fmt.Printf("Repository overlays: %s\n", *reposDir)
+		}
+		for _, layer := range layers {
+			fmt.Printf("Templates: %s\n", layer)
 		}
+	}
 
-		// Skip non-template files (optional: could filter by extension)
+	drifted := 0
+	for _, t := range targets {
+		switch {
+		case *clean:
+			fmt.Printf("Cleaning generated files in %s\n", t.OutputDir)
+		case t.Name != "":
+			fmt.Printf("Processing templates for repository: %s/%s\n", t.Config.GitHubOrg, t.Name)
+		default:
+			fmt.Printf("Processing templates for organization: %s\n", t.Config.GitHubOrg)
+		}
 		if *verbose {
-			fmt.Printf("Processing: %s\n", path)
+			fmt.Printf("Output directory: %s\n", t.OutputDir)
 		}
 
-		// Parse template
-		tmpl, err := template.ParseFiles(path)
+		prev, err := readManifest(t.OutputDir)
 		if err != nil {
-			return fmt.Errorf("failed to parse template %s: %w", path, err)
+			log.Fatal(err)
 		}
 
-		// Calculate output path
-		rel, err := filepath.Rel(*templateDir, path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
+		if *clean {
+			if err := cleanOutput(prev, t.OutputDir, *verbose); err != nil {
+				log.Fatalf("Clean failed: %v", err)
+			}
+			continue
 		}
 
-		outPath := filepath.Join(*outputDir, rel)
-
-		// Create output directory if it doesn't exist
-		outDir := filepath.Dir(outPath)
-		if err := os.MkdirAll(outDir, 0755); err != nil {
-			return fmt.Errorf("failed to create output directory %s: %w", outDir, err)
+		files, err := renderTemplates(templates, *t.Config, opts)
+		if err == nil {
+			files, err = withManifest(files)
 		}
-
-		// Create output file
-		out, err := os.Create(outPath)
 		if err != nil {
-			return fmt.Errorf("failed to create output file %s: %w", outPath, err)
+			log.Fatalf("Template processing failed: %v", err)
 		}
-		defer out.Close()
-
-		// Execute template
-		if err := tmpl.Execute(out, config); err != nil {
-			return fmt.Errorf("failed to execute template %s: %w", path, err)
+		stale := staleEntries(prev, files)
+
+		if *check {
+			paths, err := checkFiles(files, t.OutputDir, os.Stdout)
+			if err != nil {
+				log.Fatalf("Check failed: %v", err)
+			}
+			stalePaths, err := checkStale(stale, t.OutputDir, os.Stdout)
+			if err != nil {
+				log.Fatalf("Check failed: %v", err)
+			}
+			paths = append(paths, stalePaths...)
+			if len(paths) > 0 {
+				fmt.Printf("❌ %d generated file(s) in %s are out of date. Run 'task setup' to update.\n", len(paths), t.OutputDir)
+			} else {
+				fmt.Printf("✅ Generated files in %s are up to date.\n", t.OutputDir)
+			}
+			drifted += len(paths)
+			continue
 		}
 
-		if *verbose {
-			fmt.Printf("  → %s\n", outPath)
+		if err := checkUnmanaged(prev, files, t.OutputDir); err != nil {
+			log.Fatalf("Template processing failed: %v", err)
+		}
+		if err := writeFiles(files, t.OutputDir, *verbose); err != nil {
+			log.Fatalf("Template processing failed: %v", err)
+		}
+		if err := pruneStale(stale, t.OutputDir, *verbose); err != nil {
+			log.Fatalf("Template processing failed: %v", err)
 		}
-		return nil
-	})
+	}
 
-	if err != nil {
-		log.Fatalf("Template processing failed: %v", err)
+	if *clean {
+		fmt.Println("✅ Generated files removed!")
+		return
+	}
+
+	if *check {
+		if drifted > 0 {
+			os.Exit(1)
+		}
+		return
 	}
 
 	fmt.Println("✅ Template processing complete!")
 }
+
+// prepareTargets applies -org and -data to every target and validates its data
+func prepareTargets(targets []target, org, dataFile string) error {
+	for _, t := range targets {
+		if org != "" {
+			t.Config.GitHubOrg = org
+		}
+		t.Config.DataFile = dataFile
+		if err := t.Config.Validate(); err != nil {
+			if t.Name != "" {
+				return fmt.Errorf("invalid template data for %s: %w", t.Name, err)
+			}
+			return fmt.Errorf("invalid template data: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
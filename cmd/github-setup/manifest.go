@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// manifestName is the file in each output directory listing what github-setup
+// generated there; files not listed are never modified or removed
+const manifestName = ".github-setup-manifest.json"
+
+// manifest records the generated files of an output directory
+type manifest struct {
+	Files []manifestEntry `json:"files"`
+}
+
+// manifestEntry is one generated file
+type manifestEntry struct {
+	Path     string `json:"path"`     // slash-separated, relative to the output directory
+	Template string `json:"template"` // relative to the template directory
+	SHA256   string `json:"sha256"`
+}
+
+// withManifest appends the manifest describing files to them, so it is
+// written and checked like any other output
+func withManifest(files []renderedFile) ([]renderedFile, error) {
+	m := manifest{Files: make([]manifestEntry, 0, len(files))}
+	for _, file := range files {
+		m.Files = append(m.Files, manifestEntry{
+			Path:     filepath.ToSlash(file.Path),
+			Template: filepath.ToSlash(file.Source),
+			SHA256:   sha256Hex(file.Content),
+		})
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	data = append(data, '\n')
+
+	return append(files, renderedFile{Path: manifestName, Template: "(manifest)", Content: data}), nil
+}
+
+// readManifest returns the manifest in outputDir, or nil when there is none
+func readManifest(outputDir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(outputDir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return parseManifest(data)
+}
+
+// parseManifest decodes a manifest, rejecting paths outside the output directory
+func parseManifest(data []byte) (*manifest, error) {
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", manifestName, err)
+	}
+	for _, entry := range m.Files {
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) || path.Base(entry.Path) == manifestName {
+			return nil, fmt.Errorf("invalid manifest %s: bad path %q", manifestName, entry.Path)
+		}
+	}
+	return &m, nil
+}
+
+// staleEntries returns the files prev lists that files no longer produce
+func staleEntries(prev *manifest, files []renderedFile) []manifestEntry {
+	if prev == nil {
+		return nil
+	}
+
+	current := make(map[string]bool, len(files))
+	for _, file := range files {
+		current[filepath.ToSlash(file.Path)] = true
+	}
+
+	var stale []manifestEntry
+	for _, entry := range prev.Files {
+		if !current[entry.Path] {
+			stale = append(stale, entry)
+		}
+	}
+	return stale
+}
+
+// checkUnmanaged refuses to overwrite files the manifest does not list. Without
+// a manifest everything is adopted, as earlier versions wrote no manifest.
+func checkUnmanaged(prev *manifest, files []renderedFile, outputDir string) error {
+	if prev == nil {
+		return nil
+	}
+
+	managed := make(map[string]bool, len(prev.Files))
+	for _, entry := range prev.Files {
+		managed[entry.Path] = true
+	}
+
+	for _, file := range files {
+		if file.Path == manifestName || managed[filepath.ToSlash(file.Path)] {
+			continue
+		}
+		outPath := filepath.Join(outputDir, file.Path)
+		current, err := os.ReadFile(outPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", outPath, err)
+		}
+		if !bytes.Equal(current, file.Content) {
+			return fmt.Errorf("refusing to overwrite %s: it was not generated by github-setup (remove it to let %s replace it)", outPath, file.Template)
+		}
+	}
+	return nil
+}
+
+// pruneStale removes generated files that are unchanged since they were
+// written; edited ones are left in place and reported
+func pruneStale(stale []manifestEntry, outputDir string, verbose bool) error {
+	for _, entry := range stale {
+		outPath := filepath.Join(outputDir, filepath.FromSlash(entry.Path))
+		current, err := os.ReadFile(outPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", outPath, err)
+		}
+
+		if sha256Hex(current) != entry.SHA256 {
+			fmt.Printf("⚠️ Keeping %s: it was edited after github-setup generated it from %s\n", outPath, entry.Template)
+			continue
+		}
+
+		if err := os.Remove(outPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", outPath, err)
+		}
+		if verbose {
+			fmt.Printf("  ✗ %s\n", outPath)
+		}
+
+		// Remove directories the file leaves empty, up to the output directory
+		for dir := filepath.Dir(outPath); dir != filepath.Clean(outputDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// cleanOutput removes every file the manifest lists, then the manifest
+func cleanOutput(prev *manifest, outputDir string, verbose bool) error {
+	if prev == nil {
+		fmt.Printf("No %s in %s, nothing to clean\n", manifestName, outputDir)
+		return nil
+	}
+
+	if err := pruneStale(prev.Files, outputDir, verbose); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(outputDir, manifestName)); err != nil {
+		return fmt.Errorf("failed to remove manifest: %w", err)
+	}
+	return nil
+}
+
+// checkStale writes a deletion diff to w for each stale file still on disk
+// and returns their paths
+func checkStale(stale []manifestEntry, outputDir string, w io.Writer) ([]string, error) {
+	var drifted []string
+	for _, entry := range stale {
+		outPath := filepath.Join(outputDir, filepath.FromSlash(entry.Path))
+		current, err := os.ReadFile(outPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return drifted, fmt.Errorf("failed to read %s: %w", outPath, err)
+		}
+
+		drifted = append(drifted, outPath)
+		if _, err := io.WriteString(w, unifiedDiff(outPath, "/dev/null (template "+entry.Template+" removed)", current, nil)); err != nil {
+			return drifted, err
+		}
+	}
+	return drifted, nil
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
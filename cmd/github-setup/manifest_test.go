@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files under dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// generated renders files and their manifest into dir, as the last run did
+func generated(t *testing.T, dir string, files map[string]string) *manifest {
+	t.Helper()
+	rendered := syncFiles(t, files)
+	if err := writeFiles(rendered, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	prev, err := readManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prev
+}
+
+func TestPruneStale(t *testing.T) {
+	dir := t.TempDir()
+	prev := generated(t, dir, map[string]string{
+		"CODEOWNERS":             "* @acme/owners\n",
+		"workflows/old.yml":      "name: old\n",
+		"workflows/tuned.yml":    "name: tuned\n",
+		"issue-templates/bug.md": "bug\n",
+		"gone.md":                "gone\n",
+	})
+	writeTree(t, dir, map[string]string{"workflows/tuned.yml": "name: tuned by hand\n"})
+	os.Remove(filepath.Join(dir, "gone.md")) // already deleted by hand
+
+	files := syncFiles(t, map[string]string{"CODEOWNERS": "* @acme/owners\n"})
+	stale := staleEntries(prev, files)
+	if len(stale) != 4 {
+		t.Fatalf("staleEntries = %v, want the 4 files no template produces", stale)
+	}
+	if err := pruneStale(stale, dir, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"CODEOWNERS", true},          // still generated
+		{"workflows/old.yml", false},  // unchanged, so removed
+		{"workflows/tuned.yml", true}, // edited, so kept
+		{"issue-templates", false},    // left empty, so removed
+		{"workflows", true},           // still holds tuned.yml
+		{manifestName, true},          // rewritten by the caller
+	}
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(tt.path)))
+		if exists := err == nil; exists != tt.want {
+			t.Errorf("%s exists = %v, want %v", tt.path, exists, tt.want)
+		}
+	}
+}
+
+func TestCheckUnmanaged(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]string // files on disk besides the last run's output
+		noPrev   bool              // no manifest from an earlier run
+		wantErr  string
+	}{
+		{"new file", nil, false, ""},
+		{"identical unmanaged file", map[string]string{"dependabot.yml": "version: 2\n"}, false, ""},
+		{"edited unmanaged file", map[string]string{"dependabot.yml": "version: 2 # mine\n"}, false, "refusing to overwrite"},
+		{"no manifest adopts everything", map[string]string{"dependabot.yml": "version: 2 # mine\n"}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			prev := generated(t, dir, map[string]string{"CODEOWNERS": "* @acme/owners\n"})
+			if tt.noPrev {
+				prev = nil
+			}
+			writeTree(t, dir, map[string]string{"CODEOWNERS": "* @acme/owners # edited\n"})
+			writeTree(t, dir, tt.existing)
+
+			// Managed files are overwritten even when edited
+			files := syncFiles(t, map[string]string{"CODEOWNERS": "* @acme/admins\n", "dependabot.yml": "version: 2\n"})
+			err := checkUnmanaged(prev, files, dir)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkUnmanaged: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkUnmanaged = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckStale(t *testing.T) {
+	dir := t.TempDir()
+	prev := generated(t, dir, map[string]string{"CODEOWNERS": "* @acme/owners\n", "workflows/old.yml": "name: old\n"})
+
+	var out bytes.Buffer
+	paths, err := checkStale(staleEntries(prev, syncFiles(t, map[string]string{"CODEOWNERS": "* @acme/owners\n"})), dir, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != filepath.Join(dir, "workflows", "old.yml") {
+		t.Errorf("checkStale = %v, want workflows/old.yml", paths)
+	}
+	if !strings.Contains(out.String(), "-name: old") {
+		t.Errorf("checkStale diff:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "workflows", "old.yml")); err != nil {
+		t.Errorf("checkStale removed the file: %v", err)
+	}
+}
+
+func TestParseManifestRejectsPathsOutsideOutput(t *testing.T) {
+	for _, path := range []string{"../escape", "/etc/passwd", manifestName, "sub/" + manifestName} {
+		data := []byte(`{"files":[{"path":"` + path + `","template":"x","sha256":"0"}]}`)
+		if _, err := parseManifest(data); err == nil {
+			t.Errorf("parseManifest accepted path %q", path)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultLockFile pins remote template sources to a commit or archive hash
+const defaultLockFile = "github-setup.lock"
+
+// commitPattern matches a full commit hash, usable as a ref without resolving it
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// remoteSource is a -templates value naming a git repository or a tar archive,
+// e.g. https://github.com/org/.github.git//templates?ref=v1 or templates.tar.gz
+type remoteSource struct {
+	Raw    string // the -templates value, as recorded in the lock file
+	Git    bool
+	URL    string // repository URL or archive path
+	Subdir string // directory inside the repository or archive holding the templates
+	Ref    string // branch, tag or commit; HEAD when empty
+}
+
+// templateLock is the lock file, pinning what a remote source resolved to
+type templateLock struct {
+	Source string `json:"source"`
+	Commit string `json:"commit,omitempty"` // for git repositories
+	SHA256 string `json:"sha256,omitempty"` // for archives
+}
+
+// parseRemoteSource recognises git URLs (optionally prefixed git+) and tar
+// archives; anything else is a template directory
+func parseRemoteSource(value string) (remoteSource, bool) {
+	src := remoteSource{Raw: value}
+	rest := value
+
+	switch {
+	case strings.HasPrefix(rest, "git+"):
+		src.Git, rest = true, strings.TrimPrefix(rest, "git+")
+	case strings.HasPrefix(rest, "git@"):
+		src.Git = true
+	default:
+		for _, scheme := range []string{"https://", "http://", "ssh://", "git://", "file://"} {
+			if strings.HasPrefix(rest, scheme) {
+				src.Git = true
+			}
+		}
+	}
+
+	if i := strings.LastIndex(rest, "?ref="); i >= 0 {
+		rest, src.Ref = rest[:i], rest[i+len("?ref="):]
+	}
+
+	// The subdirectory follows a double slash after the scheme
+	start := 0
+	if i := strings.Index(rest, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.Index(rest[start:], "//"); i >= 0 {
+		rest, src.Subdir = rest[:start+i], rest[start+i+2:]
+	}
+	src.URL = rest
+
+	if !src.Git {
+		for _, ext := range []string{".tar", ".tar.gz", ".tgz"} {
+			if strings.HasSuffix(src.URL, ext) {
+				return src, true
+			}
+		}
+		return src, false
+	}
+	return src, true
+}
+
+// layer fetches the source into the cache, pinning it in lockFile. With
+// update the lock is ignored and rewritten; with readOnly the lock must
+// already pin the source.
+func (s remoteSource) layer(lockFile string, update, readOnly bool) (templateLayer, error) {
+	lock, err := readLock(lockFile)
+	if err != nil {
+		return templateLayer{}, err
+	}
+	pinned := lock != nil && lock.Source == s.Raw && !update &&
+		(s.Git && commitPattern.MatchString(lock.Commit) || !s.Git && lock.SHA256 != "")
+	if !pinned && readOnly {
+		return templateLayer{}, fmt.Errorf("%s is not pinned in %s; run github-setup without -check or -dry-run to pin it", s.Raw, lockFile)
+	}
+
+	cache, err := cacheDir()
+	if err != nil {
+		return templateLayer{}, err
+	}
+
+	var root, name string
+	next := templateLock{Source: s.Raw}
+	if s.Git {
+		commit := ""
+		if pinned {
+			commit = lock.Commit
+		} else if commit, err = s.resolve(); err != nil {
+			return templateLayer{}, err
+		}
+		if root, err = gitCheckout(cache, s.URL, s.Ref, commit); err != nil {
+			return templateLayer{}, err
+		}
+		next.Commit = commit
+		name = s.URL + "@" + commit[:12]
+	} else {
+		data, err := os.ReadFile(s.URL)
+		if err != nil {
+			return templateLayer{}, fmt.Errorf("failed to read template archive: %w", err)
+		}
+		next.SHA256 = sha256Hex(data)
+		if pinned && lock.SHA256 != next.SHA256 {
+			return templateLayer{}, fmt.Errorf("%s changed since it was pinned in %s; pass -update-templates to accept it", s.URL, lockFile)
+		}
+		root = filepath.Join(cache, "archives", next.SHA256)
+		if err := extractOnce(root, func(dir string) error { return extractTar(bytes.NewReader(data), dir) }); err != nil {
+			return templateLayer{}, fmt.Errorf("failed to extract %s: %w", s.URL, err)
+		}
+		name = s.URL
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(s.Subdir))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return templateLayer{}, fmt.Errorf("no directory %q in %s", s.Subdir, name)
+	}
+	if s.Subdir != "" {
+		name += "//" + s.Subdir
+	}
+
+	if !pinned {
+		if err := writeLock(lockFile, next); err != nil {
+			return templateLayer{}, err
+		}
+		fmt.Printf("📌 Pinned %s in %s\n", name, lockFile)
+	}
+	return templateLayer{fsys: os.DirFS(dir), name: name}, nil
+}
+
+// resolve returns the commit the ref of a git source points at
+func (s remoteSource) resolve() (string, error) {
+	if commitPattern.MatchString(s.Ref) {
+		return s.Ref, nil
+	}
+	ref := s.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	out, err := runGit("", "ls-remote", "--", s.URL, ref)
+	if err != nil {
+		return "", err
+	}
+
+	// Prefer the commit an annotated tag points at over the tag itself
+	commit := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if commit == "" || strings.HasSuffix(fields[1], "^{}") {
+			commit = fields[0]
+		}
+	}
+	if commit == "" {
+		return "", fmt.Errorf("ref %s not found in %s", ref, s.URL)
+	}
+	return commit, nil
+}
+
+// gitCheckout returns a directory holding the files of commit, fetching the
+// repository into the cache as needed
+func gitCheckout(cache, url, ref, commit string) (string, error) {
+	repoDir := filepath.Join(cache, "git", sha256Hex([]byte(url))[:16])
+	root := filepath.Join(repoDir, "trees", commit)
+	if _, err := os.Stat(root); err == nil {
+		return root, nil
+	}
+
+	gitDir := filepath.Join(repoDir, "repo.git")
+	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		if _, err := runGit("", "init", "--quiet", "--bare", gitDir); err != nil {
+			return "", err
+		}
+	}
+
+	// Fetch the ref first, then the commit itself, then everything, as servers
+	// differ in what they allow to be fetched
+	hasCommit := func() bool {
+		_, err := runGit(gitDir, "cat-file", "-e", commit+"^{commit}")
+		return err == nil
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if !hasCommit() && ref != commit {
+		runGit(gitDir, "fetch", "--quiet", "--", url, ref)
+	}
+	if !hasCommit() {
+		runGit(gitDir, "fetch", "--quiet", "--", url, commit)
+	}
+	if !hasCommit() {
+		if _, err := runGit(gitDir, "fetch", "--quiet", "--tags", "--", url, "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+			return "", err
+		}
+	}
+	if !hasCommit() {
+		return "", fmt.Errorf("commit %s not found in %s", commit, url)
+	}
+
+	archive, err := runGit(gitDir, "archive", "--format=tar", commit)
+	if err != nil {
+		return "", err
+	}
+	if err := extractOnce(root, func(dir string) error { return extractTar(bytes.NewReader(archive), dir) }); err != nil {
+		return "", fmt.Errorf("failed to extract %s at %s: %w", url, commit, err)
+	}
+	return root, nil
+}
+
+// runGit runs git, in gitDir when it is set, returning its output or an error
+// including what it printed
+func runGit(gitDir string, args ...string) ([]byte, error) {
+	command := args[0]
+	if gitDir != "" {
+		args = append([]string{"--git-dir", gitDir}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// extractOnce fills dir with extract unless it already exists, extracting to
+// a temporary directory first so that an interrupted run leaves no partial tree
+func extractOnce(dir string, extract func(string) error) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := extract(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// Another run may have extracted the same tree meanwhile
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// extractTar writes the regular files of a tar archive, optionally gzipped,
+// under dir; links and entries outside dir are skipped
+func extractTar(r io.Reader, dir string) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(strings.TrimPrefix(header.Name, "./"))
+		if header.Typeflag != tar.TypeReg || !filepath.IsLocal(name) {
+			continue
+		}
+
+		outPath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// cacheDir is where remote template sources are fetched to: GITHUB_SETUP_CACHE,
+// or github-setup in the user cache directory
+func cacheDir() (string, error) {
+	if dir := os.Getenv("GITHUB_SETUP_CACHE"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find a cache directory (set GITHUB_SETUP_CACHE): %w", err)
+	}
+	return filepath.Join(dir, "github-setup"), nil
+}
+
+// readLock returns the lock file, or nil when there is none
+func readLock(path string) (*templateLock, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	var lock templateLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// writeLock writes the lock file
+func writeLock(path string, lock templateLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
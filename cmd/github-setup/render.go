@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// renderedFile is a template rendered in memory, or a file copied verbatim
+type renderedFile struct {
+	Path     string // relative to the output directory
+	Template string // template it was rendered from
+	Source   string // Template relative to the template directory
+	Content  []byte
+}
+
+// templateSuffix marks files to render; it is stripped from the output name
+const templateSuffix = ".tmpl"
+
+// delimsDirective is an optional first line choosing a file's delimiters,
+// e.g. "# github-setup: delims=[[ ]]"; it is not copied to the output
+var delimsDirective = regexp.MustCompile(`^[ \t]*(?:#|//|<!--)[ \t]*github-setup:[ \t]*delims=(\S+)[ \t]+(\S+?)[ \t]*(?:-->)?[ \t]*\r?\n`)
+
+// renderOptions controls how templates are parsed and rendered
+type renderOptions struct {
+	Verbose    bool
+	AllowEnv   []string // environment variables the env function may read
+	LeftDelim  string   // default delimiters, "" for {{ and }}
+	RightDelim string
+	SuffixOnly bool // render only .tmpl files of -templates and copy the rest verbatim
+	NoValidate bool // skip checking rendered workflows, configs and CODEOWNERS
+}
+
+// renderFlags are the template flags shared by the render and sync commands
+type renderFlags struct {
+	flags       *flag.FlagSet
+	templateDir string
+	noDefaults  bool
+	lockFile    string
+	updateLock  bool
+	allowEnv    string
+	delims      string
+	suffixOnly  bool
+	noValidate  bool
+}
+
+// addRenderFlags registers the template flags on fs
+func addRenderFlags(fs *flag.FlagSet) *renderFlags {
+	f := &renderFlags{flags: fs}
+	fs.StringVar(&f.templateDir, "templates", "templates", "Templates overriding the built-in ones file by file: a directory (ignored when the default is missing), a git URL like https://host/org/repo.git//dir?ref=v1, or a .tar/.tar.gz archive")
+	fs.BoolVar(&f.noDefaults, "no-defaults", false, "Render only -templates, without the built-in templates")
+	fs.StringVar(&f.lockFile, "lock", defaultLockFile, "Lock file pinning a git or archive -templates source to a commit or hash")
+	fs.BoolVar(&f.updateLock, "update-templates", false, "Resolve a git or archive -templates source again and update the lock file")
+	fs.StringVar(&f.allowEnv, "allow-env", "", "Comma-separated environment variables templates may read with env")
+	fs.StringVar(&f.delims, "delims", "", "Template delimiters for every file, e.g. \"[[ ]]\" (a file's first line can override: # github-setup: delims=[[ ]])")
+	fs.BoolVar(&f.suffixOnly, "suffix-only", false, "Render only "+templateSuffix+" files of -templates and copy its other files verbatim (built-in templates are always rendered)")
+	fs.BoolVar(&f.noValidate, "no-validate", false, "Skip validating rendered YAML, workflows, dependabot.yml, issue forms and CODEOWNERS")
+	return f
+}
+
+// options returns the render options the flags select
+func (f *renderFlags) options(verbose bool) (renderOptions, error) {
+	opts := renderOptions{Verbose: verbose, AllowEnv: splitList(f.allowEnv), SuffixOnly: f.suffixOnly, NoValidate: f.noValidate}
+	if f.delims != "" {
+		delims := strings.Fields(f.delims)
+		if len(delims) != 2 {
+			return opts, fmt.Errorf("invalid -delims %q: expected left and right delimiters separated by a space", f.delims)
+		}
+		opts.LeftDelim, opts.RightDelim = delims[0], delims[1]
+	}
+	return opts, nil
+}
+
+// renderTemplates renders every template with config; files in _partials are
+// available to all of them but not rendered themselves
+func renderTemplates(templates []templateFile, config Config, opts renderOptions) ([]renderedFile, error) {
+	partials, err := parsePartials(templates, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out the output paths, letting later layers override earlier ones
+	var selected []templateFile
+	outputs := make(map[string]int) // output path -> index in selected
+	for _, t := range templates {
+		if t.isPartial() {
+			continue
+		}
+
+		out := t.outputPath(opts)
+		if i, ok := outputs[out]; ok {
+			if other := selected[i]; other.Layer == t.Layer {
+				return nil, fmt.Errorf("both %s and %s render to %s", other.Name, t.Name, out)
+			}
+			selected[i] = t
+			continue
+		}
+		outputs[out] = len(selected)
+		selected = append(selected, t)
+	}
+
+	var files []renderedFile
+	for _, t := range selected {
+		out := filepath.FromSlash(t.outputPath(opts))
+		source := filepath.FromSlash(t.Rel)
+
+		content, err := t.ReadFile()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", t.Name, err)
+		}
+
+		// Copy files that are not templates as they are
+		if t.copiedVerbatim(opts) {
+			if opts.Verbose {
+				fmt.Printf("Copying: %s\n", t.Name)
+			}
+			files = append(files, renderedFile{Path: out, Template: t.Name, Source: source, Content: content})
+			continue
+		}
+
+		if opts.Verbose {
+			fmt.Printf("Processing: %s\n", t.Name)
+		}
+
+		// Parse template alongside the partials
+		set, err := partials.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone partials: %w", err)
+		}
+		tmpl, err := parseTemplate(set, filepath.ToSlash(out), content, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", t.Name, err)
+		}
+		bindInclude(set)
+
+		// Execute template
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, config); err != nil {
+			return nil, fmt.Errorf("failed to execute template %s: %w", t.Name, err)
+		}
+
+		files = append(files, renderedFile{Path: out, Template: t.Name, Source: source, Content: buf.Bytes()})
+	}
+	if opts.NoValidate {
+		return files, nil
+	}
+
+	// Catch broken templates before their output lands in .github
+	if problems := validateFiles(files); len(problems) > 0 {
+		return nil, fmt.Errorf("%d problem(s) in rendered files:\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return files, nil
+}
+
+// copiedVerbatim reports whether the template is copied without rendering:
+// with SuffixOnly, files of -templates without .tmpl. The built-in templates
+// have no .tmpl suffix and are always rendered.
+func (t templateFile) copiedVerbatim(opts renderOptions) bool {
+	return opts.SuffixOnly && !t.isBuiltin() && !strings.HasSuffix(t.Rel, templateSuffix)
+}
+
+// outputPath is the path a template renders to: without .tmpl, unless it is
+// copied verbatim
+func (t templateFile) outputPath(opts renderOptions) string {
+	if t.copiedVerbatim(opts) {
+		return t.Rel
+	}
+	return strings.TrimSuffix(t.Rel, templateSuffix)
+}
+
+// parsePartials parses every template under _partials, each named by its path
+// relative to _partials without .tmpl, into one set templates are cloned from
+func parsePartials(templates []templateFile, opts renderOptions) (*template.Template, error) {
+	set := template.New(partialsDir).Funcs(templateFuncs(opts.AllowEnv))
+
+	for _, t := range templates {
+		if !t.isPartial() {
+			continue
+		}
+		content, err := t.ReadFile()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read partial %s: %w", t.Name, err)
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(t.Rel, partialsDir+"/"), templateSuffix)
+		if _, err := parseTemplate(set, name, content, opts); err != nil {
+			return nil, fmt.Errorf("failed to parse partial %s: %w", t.Name, err)
+		}
+	}
+	return set, nil
+}
+
+// parseTemplate adds content to set as name, with the delimiters from its
+// directive line or opts
+func parseTemplate(set *template.Template, name string, content []byte, opts renderOptions) (*template.Template, error) {
+	left, right := opts.LeftDelim, opts.RightDelim
+	if m := delimsDirective.FindSubmatch(content); m != nil {
+		left, right = string(m[1]), string(m[2])
+		content = content[len(m[0]):]
+	}
+	return set.New(name).Delims(left, right).Parse(string(content))
+}
+
+// writeFiles writes rendered files under outputDir
+func writeFiles(files []renderedFile, outputDir string, verbose bool) error {
+	for _, file := range files {
+		outPath := filepath.Join(outputDir, file.Path)
+
+		// Create output directory if it doesn't exist
+		outDir := filepath.Dir(outPath)
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory %s: %w", outDir, err)
+		}
+
+		if err := os.WriteFile(outPath, file.Content, 0644); err != nil {
+			return fmt.Errorf("failed to write output file %s: %w", outPath, err)
+		}
+
+		if verbose {
+			fmt.Printf("  → %s\n", outPath)
+		}
+	}
+	return nil
+}
+
+// checkFiles compares rendered files with outputDir, writing a unified diff
+// to w for each file that is missing or differs, and returns the paths that drifted.
+// Files in outputDir that no template produces are not checked.
+func checkFiles(files []renderedFile, outputDir string, w io.Writer) ([]string, error) {
+	var drifted []string
+
+	for _, file := range files {
+		outPath := filepath.Join(outputDir, file.Path)
+
+		fromName := outPath
+		current, err := os.ReadFile(outPath)
+		if os.IsNotExist(err) {
+			fromName = "/dev/null"
+		} else if err != nil {
+			return drifted, fmt.Errorf("failed to read %s: %w", outPath, err)
+		}
+
+		diff := unifiedDiff(fromName, outPath+" (rendered from "+file.Template+")", current, file.Content)
+		if diff == "" {
+			continue
+		}
+
+		drifted = append(drifted, outPath)
+		if _, err := io.WriteString(w, diff); err != nil {
+			return drifted, err
+		}
+	}
+
+	return drifted, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testConfig returns the default data for org
+func testConfig(t *testing.T, org string) Config {
+	t.Helper()
+	config, err := parseConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.GitHubOrg = org
+	return *config
+}
+
+// renderLayers renders the built-in templates overridden by user
+func renderLayers(t *testing.T, user fstest.MapFS, opts renderOptions) map[string]string {
+	t.Helper()
+	templates, err := templateFiles([]templateLayer{builtinLayer(), {fsys: user, name: "user"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := renderTemplates(templates, testConfig(t, "acme"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := make(map[string]string, len(files))
+	for _, file := range files {
+		rendered[file.Path] = string(file.Content)
+	}
+	return rendered
+}
+
+func TestRenderSuffixOnly(t *testing.T) {
+	user := fstest.MapFS{
+		"notes.md.tmpl":   {Data: []byte("Owned by {{.GitHubOrg}}\n")},
+		"raw.md":          {Data: []byte("Kept as {{.GitHubOrg}}\n")},
+		"CODEOWNERS.tmpl": {Data: []byte("* @{{.GitHubOrg}}/owners\n")},
+	}
+
+	tests := []struct {
+		suffixOnly bool
+		want       map[string]string
+	}{
+		{true, map[string]string{
+			"notes.md":   "Owned by acme\n",
+			"raw.md":     "Kept as {{.GitHubOrg}}\n",
+			"CODEOWNERS": "* @acme/owners\n",
+		}},
+		{false, map[string]string{
+			"notes.md":   "Owned by acme\n",
+			"raw.md":     "Kept as acme\n",
+			"CODEOWNERS": "* @acme/owners\n",
+		}},
+	}
+	for _, tt := range tests {
+		rendered := renderLayers(t, user, renderOptions{SuffixOnly: tt.suffixOnly})
+		for path, want := range tt.want {
+			if got := rendered[path]; got != want {
+				t.Errorf("suffix-only %v: %s = %q, want %q", tt.suffixOnly, path, got, want)
+			}
+		}
+
+		// Built-in templates have no .tmpl suffix but are always rendered
+		for path, content := range rendered {
+			if path == "raw.md" {
+				continue
+			}
+			if strings.Contains(content, "[[") || strings.Contains(content, "{{.") {
+				t.Errorf("suffix-only %v: %s left unrendered:\n%s", tt.suffixOnly, path, content)
+			}
+		}
+		if _, ok := rendered["dependabot.yml"]; !ok {
+			t.Errorf("suffix-only %v: built-in dependabot.yml missing", tt.suffixOnly)
+		}
+	}
+}
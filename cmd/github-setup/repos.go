@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultReposOutput is where -repos writes its trees unless -output is given
+const defaultReposOutput = "dist"
+
+// repoNamePattern matches the repository names GitHub accepts
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// target is one output tree and the data it is rendered with
+type target struct {
+	Name      string // repository, "" for the single tree rendered without -repos
+	Config    *Config
+	OutputDir string
+}
+
+// loadRepoTargets reads every overlay in reposDir (<name>.yaml, .yml or
+// .json), merges it on top of dataFile and returns a target per repository
+// writing to outputDir/<name>
+func loadRepoTargets(dataFile, reposDir, outputDir string) ([]target, error) {
+	entries, err := os.ReadDir(reposDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repos directory: %w", err)
+	}
+
+	var targets []target
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if !repoNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid repository name %q from %s", name, entry.Name())
+		}
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("repository %s has two overlays: %s and %s", name, other, entry.Name())
+		}
+		seen[name] = entry.Name()
+
+		config, err := loadConfig(dataFile, filepath.Join(reposDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		config.Repo = name
+
+		targets = append(targets, target{Name: name, Config: config, OutputDir: filepath.Join(outputDir, name)})
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no repository overlays (*.yaml, *.yml or *.json) in %s", reposDir)
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joeblew999/.github/templates"
+)
+
+// templateLayer is a tree of templates. Later layers override earlier ones:
+// a file replaces the file of an earlier layer that renders to the same path.
+type templateLayer struct {
+	fsys fs.FS
+	dir  string // directory on disk given as -templates
+	name string // otherwise, the name files are shown under as <name>:<path>
+}
+
+// templateFile is a template found in one of the layers
+type templateFile struct {
+	Rel   string // slash-separated, relative to the layer root
+	Name  string // path on disk, or <layer name>:<rel>
+	Layer int    // index of the layer it comes from
+	fsys  fs.FS
+}
+
+// builtinLayer returns the templates built into the binary
+func builtinLayer() templateLayer {
+	return templateLayer{fsys: templates.FS, name: "builtin"}
+}
+
+// String describes the layer in messages
+func (l templateLayer) String() string {
+	switch {
+	case l.dir != "":
+		return l.dir
+	case l.fsys == templates.FS:
+		return "built-in templates"
+	}
+	return l.name
+}
+
+// templateLayers returns the layers the flags select: the built-in templates
+// unless -no-defaults, then -templates. The default template directory may be
+// missing, so that the binary runs standalone in any repository. A remote
+// -templates source is pinned in the lock file, unless readOnly.
+func (f *renderFlags) templateLayers(readOnly bool) ([]templateLayer, error) {
+	var layers []templateLayer
+	if !f.noDefaults {
+		layers = append(layers, builtinLayer())
+	}
+
+	if src, ok := parseRemoteSource(f.templateDir); ok {
+		layer, err := src.layer(f.lockFile, f.updateLock, readOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch templates: %w", err)
+		}
+		return append(layers, layer), nil
+	}
+
+	explicit := false
+	f.flags.Visit(func(fl *flag.Flag) {
+		if fl.Name == "templates" {
+			explicit = true
+		}
+	})
+
+	info, err := os.Stat(f.templateDir)
+	switch {
+	case os.IsNotExist(err) && !explicit && !f.noDefaults:
+		return layers, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read template directory: %w", err)
+	case !info.IsDir():
+		return nil, fmt.Errorf("template directory %s is not a directory", f.templateDir)
+	}
+	return append(layers, templateLayer{fsys: os.DirFS(f.templateDir), dir: f.templateDir}), nil
+}
+
+// templateFiles lists the files of every layer, sorted by path. A file in a
+// later layer with the same path replaces the earlier one. Go files, such as
+// the one embedding the built-in templates, are not templates and are skipped.
+func templateFiles(layers []templateLayer) ([]templateFile, error) {
+	byRel := make(map[string]templateFile)
+	for i, layer := range layers {
+		err := fs.WalkDir(layer.fsys, ".", func(rel string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || path.Ext(rel) == ".go" {
+				return nil
+			}
+
+			name := layer.name + ":" + rel
+			if layer.dir != "" {
+				name = filepath.Join(layer.dir, filepath.FromSlash(rel))
+			}
+			byRel[rel] = templateFile{Rel: rel, Name: name, Layer: i, fsys: layer.fsys}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list templates in %s: %w", layer, err)
+		}
+	}
+
+	files := make([]templateFile, 0, len(byRel))
+	for _, file := range byRel {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Rel < files[j].Rel })
+	return files, nil
+}
+
+// ReadFile returns the content of the template
+func (t templateFile) ReadFile() ([]byte, error) {
+	return fs.ReadFile(t.fsys, t.Rel)
+}
+
+// isBuiltin reports whether the template is built into the binary
+func (t templateFile) isBuiltin() bool {
+	return t.fsys == templates.FS
+}
+
+// isPartial reports whether the template is under _partials
+func (t templateFile) isPartial() bool {
+	return strings.HasPrefix(t.Rel, partialsDir+"/")
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// syncOptions are the settings of the sync subcommand shared by every repository
+type syncOptions struct {
+	path   string // directory in the repository the rendered files go to
+	branch string
+	title  string
+	dryRun bool
+}
+
+// syncResult is the outcome for one repository, printed in the summary
+type syncResult struct {
+	Repo    string
+	Status  string
+	Changed int
+	Err     error
+}
+
+// runSync implements the "sync" subcommand
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	org := fs.String("org", "", "GitHub organization name (default: org in the data file)")
+	dataFile := fs.String("data", "", "YAML or JSON data file merged under every repository overlay")
+	reposDir := fs.String("repos", "", "Directory of per-repository overlays (<name>.yaml); each name is a repository to sync")
+	render := addRenderFlags(fs)
+	apiURL := fs.String("api-url", envOr("GITHUB_API_URL", defaultGitHubAPIURL), "GitHub API base URL")
+	opts := syncOptions{}
+	fs.StringVar(&opts.path, "path", ".github", "Directory in each repository to write the rendered files to")
+	fs.StringVar(&opts.branch, "branch", "github-setup/sync", "Branch to push the changes to")
+	fs.StringVar(&opts.title, "title", "", "Pull request title (default: Sync .github files from <org>/.github templates)")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Print the diff for each repository without pushing or opening pull requests")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: github-setup sync -repos <dir> [flags]\n\n")
+		fmt.Fprintf(fs.Output(), "Renders the templates for every repository overlay and opens (or updates) a pull\n")
+		fmt.Fprintf(fs.Output(), "request in each repository whose files differ. Authenticates with GITHUB_TOKEN.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *reposDir == "" {
+		fs.Usage()
+		return fmt.Errorf("sync needs -repos")
+	}
+
+	targets, err := loadRepoTargets(*dataFile, *reposDir, "")
+	if err != nil {
+		return err
+	}
+	if err := prepareTargets(targets, *org, *dataFile); err != nil {
+		return err
+	}
+
+	renderOpts, err := render.options(false)
+	if err != nil {
+		return err
+	}
+	layers, err := render.templateLayers(opts.dryRun)
+	if err != nil {
+		return err
+	}
+	templates, err := templateFiles(layers)
+	if err != nil {
+		return err
+	}
+
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" && !opts.dryRun {
+		return fmt.Errorf("GITHUB_TOKEN is required to push changes (or use -dry-run)")
+	}
+	gh := newGitHubClient(*apiURL, token)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var results []syncResult
+	for _, t := range targets {
+		fmt.Printf("🔄 Syncing %s/%s\n", t.Config.GitHubOrg, t.Name)
+
+		result := syncResult{Repo: t.Config.GitHubOrg + "/" + t.Name}
+		files, err := renderTemplates(templates, *t.Config, renderOpts)
+		if err == nil {
+			files, err = withManifest(files)
+		}
+		if err != nil {
+			result.Err = fmt.Errorf("template processing failed: %w", err)
+		} else {
+			result.Status, result.Changed, result.Err = syncRepo(ctx, gh, t, files, opts)
+		}
+		results = append(results, result)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return printSyncSummary(results)
+}
+
+// syncRepo pushes the rendered files of one repository to the sync branch and
+// makes sure a pull request is open for it, returning what it did and how many
+// files differ from the default branch
+func syncRepo(ctx context.Context, gh *githubClient, t target, files []renderedFile, opts syncOptions) (string, int, error) {
+	owner, repo := t.Config.GitHubOrg, t.Name
+
+	base, err := gh.DefaultBranch(ctx, owner, repo)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get repository: %w", err)
+	}
+	baseSHA, err := gh.BranchSHA(ctx, owner, repo, base)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get %s: %w", base, err)
+	}
+	baseTree, baseBlobs, err := gh.Tree(ctx, owner, repo, baseSHA)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to list %s: %w", base, err)
+	}
+
+	changed := make(map[string][]byte)
+	for _, file := range files {
+		dest := path.Join(opts.path, filepath.ToSlash(file.Path))
+		if baseBlobs[dest] != blobSHA(file.Content) {
+			changed[dest] = file.Content
+		}
+	}
+	deletes, err := staleRemoteFiles(ctx, gh, owner, repo, baseBlobs, files, opts.path)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(changed) == 0 && len(deletes) == 0 {
+		return "up to date", 0, nil
+	}
+	total := len(changed) + len(deletes)
+
+	if opts.dryRun {
+		for _, dest := range sortedKeys(changed) {
+			var current []byte
+			if sha, ok := baseBlobs[dest]; ok {
+				if current, err = gh.Blob(ctx, owner, repo, sha); err != nil {
+					return "", total, fmt.Errorf("failed to read %s: %w", dest, err)
+				}
+			}
+			fromName := "a/" + dest
+			if current == nil {
+				fromName = "/dev/null"
+			}
+			fmt.Print(unifiedDiff(fromName, "b/"+dest, current, changed[dest]))
+		}
+		for _, dest := range deletes {
+			current, err := gh.Blob(ctx, owner, repo, baseBlobs[dest])
+			if err != nil {
+				return "", total, fmt.Errorf("failed to read %s: %w", dest, err)
+			}
+			fmt.Print(unifiedDiff("a/"+dest, "/dev/null", current, nil))
+		}
+		return "would update", total, nil
+	}
+
+	// Reuse the sync branch when it already carries exactly these files
+	branchSHA, err := gh.BranchSHA(ctx, owner, repo, opts.branch)
+	exists := err == nil
+	if err != nil && !errors.Is(err, errNotFound) {
+		return "", total, fmt.Errorf("failed to get %s: %w", opts.branch, err)
+	}
+	current := false
+	if exists {
+		_, branchBlobs, err := gh.Tree(ctx, owner, repo, branchSHA)
+		if err != nil {
+			return "", total, fmt.Errorf("failed to list %s: %w", opts.branch, err)
+		}
+		current = true
+		for dest, content := range changed {
+			if branchBlobs[dest] != blobSHA(content) {
+				current = false
+			}
+		}
+		for _, dest := range deletes {
+			if _, ok := branchBlobs[dest]; ok {
+				current = false
+			}
+		}
+	}
+
+	if !current {
+		message := fmt.Sprintf("chore: sync %s files from templates", opts.path)
+		commit, err := gh.CreateCommit(ctx, owner, repo, baseSHA, baseTree, message, changed, deletes)
+		if err != nil {
+			return "", total, fmt.Errorf("failed to commit: %w", err)
+		}
+		if err := gh.SetBranch(ctx, owner, repo, opts.branch, commit, exists); err != nil {
+			return "", total, fmt.Errorf("failed to push %s: %w", opts.branch, err)
+		}
+	}
+
+	pr, err := gh.OpenPullRequest(ctx, owner, repo, opts.branch)
+	if err != nil {
+		return "", total, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if pr != nil {
+		if current {
+			return fmt.Sprintf("pull request #%d already up to date %s", pr.Number, pr.HTMLURL), total, nil
+		}
+		return fmt.Sprintf("updated pull request #%d %s", pr.Number, pr.HTMLURL), total, nil
+	}
+
+	title := opts.title
+	if title == "" {
+		title = fmt.Sprintf("Sync %s files from %s/.github templates", opts.path, owner)
+	}
+	pr, err = gh.CreatePullRequest(ctx, owner, repo, opts.branch, base, title, pullRequestBody(owner, changed, deletes))
+	if err != nil {
+		return "", total, fmt.Errorf("failed to open pull request: %w", err)
+	}
+	return fmt.Sprintf("opened pull request #%d %s", pr.Number, pr.HTMLURL), total, nil
+}
+
+// pullRequestBody lists the files a sync pull request changes
+func pullRequestBody(owner string, changed map[string][]byte, deletes []string) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Generated by `github-setup sync` from the %s/.github templates.\n\n", owner)
+	fmt.Fprintf(&body, "Changed files:\n\n")
+	for _, dest := range sortedKeys(changed) {
+		fmt.Fprintf(&body, "- `%s`\n", dest)
+	}
+	for _, dest := range deletes {
+		fmt.Fprintf(&body, "- `%s` (removed: its template no longer exists)\n", dest)
+	}
+	fmt.Fprintf(&body, "\nEdit the templates or the repository overlay rather than these files; the next sync overwrites them.\n")
+	return body.String()
+}
+
+// staleRemoteFiles returns the files the repository's manifest lists that the
+// templates no longer produce and that are unchanged since they were generated
+func staleRemoteFiles(ctx context.Context, gh *githubClient, owner, repo string, blobs map[string]string, files []renderedFile, dir string) ([]string, error) {
+	sha, ok := blobs[path.Join(dir, manifestName)]
+	if !ok {
+		return nil, nil
+	}
+	data, err := gh.Blob(ctx, owner, repo, sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	prev, err := parseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var deletes []string
+	for _, entry := range staleEntries(prev, files) {
+		dest := path.Join(dir, entry.Path)
+		sha, ok := blobs[dest]
+		if !ok {
+			continue
+		}
+		content, err := gh.Blob(ctx, owner, repo, sha)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dest, err)
+		}
+		if sha256Hex(content) == entry.SHA256 {
+			deletes = append(deletes, dest)
+		}
+	}
+	sort.Strings(deletes)
+	return deletes, nil
+}
+
+// printSyncSummary prints one line per repository and fails if any did
+func printSyncSummary(results []syncResult) error {
+	fmt.Println()
+	fmt.Println("📋 Sync summary:")
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Printf("  ❌ %s: %v\n", r.Repo, r.Err)
+		case r.Changed == 0:
+			fmt.Printf("  ✅ %s: %s\n", r.Repo, r.Status)
+		default:
+			fmt.Printf("  🔀 %s: %s (%d file(s))\n", r.Repo, r.Status, r.Changed)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed", failed, len(results))
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// envOr returns the environment variable name, or fallback when it is unset
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
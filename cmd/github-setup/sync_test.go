@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitHub is an in-memory repository behind the REST endpoints sync uses
+type fakeGitHub struct {
+	mu       sync.Mutex
+	blobs    map[string][]byte            // blob SHA -> content
+	trees    map[string]map[string]string // tree SHA -> path -> blob SHA
+	commits  map[string]string            // commit SHA -> tree SHA
+	branches map[string]string            // branch -> commit SHA
+	pulls    []fakePull
+	writes   []string // method and path of every request that changed something
+	nextID   int
+}
+
+// fakePull is an open pull request
+type fakePull struct {
+	pullRequest
+	Head, Base, Title string
+}
+
+// newFakeGitHub returns a repository whose main branch holds files
+func newFakeGitHub(t *testing.T, files map[string]string) (*fakeGitHub, *githubClient) {
+	f := &fakeGitHub{
+		blobs:    make(map[string][]byte),
+		trees:    make(map[string]map[string]string),
+		commits:  make(map[string]string),
+		branches: make(map[string]string),
+	}
+	tree := make(map[string]string)
+	for path, content := range files {
+		tree[path] = f.blob([]byte(content))
+	}
+	f.branches["main"] = f.commit(f.tree(tree))
+
+	srv := httptest.NewServer(http.StripPrefix("/repos/acme/widgets", f))
+	t.Cleanup(srv.Close)
+	return f, newGitHubClient(srv.URL, "token")
+}
+
+// id returns a new object ID
+func (f *fakeGitHub) id(kind string) string {
+	f.nextID++
+	return fmt.Sprintf("%s%d", kind, f.nextID)
+}
+
+func (f *fakeGitHub) blob(content []byte) string {
+	sha := blobSHA(content)
+	f.blobs[sha] = content
+	return sha
+}
+
+func (f *fakeGitHub) tree(files map[string]string) string {
+	sha := f.id("tree")
+	f.trees[sha] = files
+	return sha
+}
+
+func (f *fakeGitHub) commit(tree string) string {
+	sha := f.id("commit")
+	f.commits[sha] = tree
+	return sha
+}
+
+// files returns the content of every file on branch
+func (f *fakeGitHub) files(branch string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	files := make(map[string]string)
+	for path, sha := range f.trees[f.commits[f.branches[branch]]] {
+		files[path] = string(f.blobs[sha])
+	}
+	return files
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method != http.MethodGet {
+		f.writes = append(f.writes, r.Method+" "+r.URL.Path)
+	}
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	reply := func(v interface{}) { json.NewEncoder(w).Encode(v) }
+
+	route := r.Method + " " + r.URL.Path
+	switch {
+	case route == "GET ":
+		reply(map[string]string{"default_branch": "main"})
+
+	case strings.HasPrefix(route, "GET /git/ref/heads/"):
+		commit, ok := f.branches[strings.TrimPrefix(r.URL.Path, "/git/ref/heads/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		reply(map[string]interface{}{"object": map[string]string{"sha": commit}})
+
+	case strings.HasPrefix(route, "GET /git/trees/"):
+		tree := f.commits[strings.TrimPrefix(r.URL.Path, "/git/trees/")]
+		var entries []treeEntry
+		for path, sha := range f.trees[tree] {
+			entries = append(entries, treeEntry{Path: path, Type: "blob", SHA: sha})
+		}
+		reply(map[string]interface{}{"sha": tree, "tree": entries})
+
+	case strings.HasPrefix(route, "GET /git/blobs/"):
+		content := f.blobs[strings.TrimPrefix(r.URL.Path, "/git/blobs/")]
+		reply(map[string]string{"content": base64.StdEncoding.EncodeToString(content), "encoding": "base64"})
+
+	case route == "POST /git/trees":
+		files := make(map[string]string)
+		for path, sha := range f.trees[body["base_tree"].(string)] {
+			files[path] = sha
+		}
+		for _, e := range body["tree"].([]interface{}) {
+			entry := e.(map[string]interface{})
+			path := entry["path"].(string)
+			if content, ok := entry["content"].(string); ok {
+				files[path] = f.blob([]byte(content))
+			} else {
+				delete(files, path)
+			}
+		}
+		reply(map[string]string{"sha": f.tree(files)})
+
+	case route == "POST /git/commits":
+		reply(map[string]string{"sha": f.commit(body["tree"].(string))})
+
+	case route == "POST /git/refs":
+		f.branches[strings.TrimPrefix(body["ref"].(string), "refs/heads/")] = body["sha"].(string)
+		reply(map[string]string{})
+
+	case strings.HasPrefix(route, "PATCH /git/refs/heads/"):
+		f.branches[strings.TrimPrefix(r.URL.Path, "/git/refs/heads/")] = body["sha"].(string)
+		reply(map[string]string{})
+
+	case route == "GET /pulls":
+		open := []pullRequest{}
+		for _, pr := range f.pulls {
+			if "acme:"+pr.Head == r.URL.Query().Get("head") {
+				open = append(open, pr.pullRequest)
+			}
+		}
+		reply(open)
+
+	case route == "POST /pulls":
+		pr := fakePull{Head: body["head"].(string), Base: body["base"].(string), Title: body["title"].(string)}
+		pr.Number = len(f.pulls) + 1
+		pr.HTMLURL = fmt.Sprintf("https://github.com/acme/widgets/pull/%d", pr.Number)
+		f.pulls = append(f.pulls, pr)
+		reply(pr.pullRequest)
+
+	default:
+		http.Error(w, "unexpected "+route, http.StatusBadRequest)
+	}
+}
+
+// syncFiles returns rendered files with their manifest
+func syncFiles(t *testing.T, files map[string]string) []renderedFile {
+	t.Helper()
+	var rendered []renderedFile
+	for path, content := range files {
+		rendered = append(rendered, renderedFile{Path: path, Template: path, Source: path, Content: []byte(content)})
+	}
+	sort.Slice(rendered, func(i, j int) bool { return rendered[i].Path < rendered[j].Path })
+
+	rendered, err := withManifest(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rendered
+}
+
+// repoFiles returns files as they sit in the repository under .github
+func repoFiles(files []renderedFile) map[string]string {
+	repo := make(map[string]string, len(files))
+	for _, file := range files {
+		repo[".github/"+file.Path] = string(file.Content)
+	}
+	return repo
+}
+
+func TestSyncRepo(t *testing.T) {
+	target := target{Name: "widgets", Config: &Config{GitHubOrg: "acme"}}
+	opts := syncOptions{path: ".github", branch: "github-setup/sync"}
+	files := syncFiles(t, map[string]string{"CODEOWNERS": "* @acme/owners\n", "dependabot.yml": "version: 2\n"})
+
+	t.Run("up to date", func(t *testing.T) {
+		gh, client := newFakeGitHub(t, repoFiles(files))
+
+		status, changed, err := syncRepo(context.Background(), client, target, files, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != "up to date" || changed != 0 || len(gh.writes) > 0 {
+			t.Errorf("syncRepo = %q, %d changed, writes %v; want up to date without writes", status, changed, gh.writes)
+		}
+	})
+
+	t.Run("new branch and pull request", func(t *testing.T) {
+		gh, client := newFakeGitHub(t, map[string]string{"README.md": "widgets\n"})
+
+		status, changed, err := syncRepo(context.Background(), client, target, files, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(status, "opened pull request #1") || changed != 3 {
+			t.Errorf("syncRepo = %q, %d changed; want opened pull request #1, 3 changed", status, changed)
+		}
+
+		want := repoFiles(files)
+		want["README.md"] = "widgets\n"
+		if got := gh.files(opts.branch); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s holds %v, want %v", opts.branch, got, want)
+		}
+		if len(gh.files("main")) != 1 {
+			t.Errorf("main changed: %v", gh.files("main"))
+		}
+		if pr := gh.pulls[0]; pr.Head != opts.branch || pr.Base != "main" || pr.Title != "Sync .github files from acme/.github templates" {
+			t.Errorf("pull request %+v", pr)
+		}
+	})
+
+	t.Run("existing pull request", func(t *testing.T) {
+		gh, client := newFakeGitHub(t, nil)
+		if _, _, err := syncRepo(context.Background(), client, target, files, opts); err != nil {
+			t.Fatal(err)
+		}
+		writes := len(gh.writes)
+
+		// The branch already carries the files: nothing is pushed
+		status, _, err := syncRepo(context.Background(), client, target, files, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(status, "pull request #1 already up to date") || len(gh.writes) != writes {
+			t.Errorf("syncRepo = %q, writes %v; want pull request #1 already up to date without writes", status, gh.writes[writes:])
+		}
+
+		// New content is force-pushed to the branch of the open pull request
+		updated := syncFiles(t, map[string]string{"CODEOWNERS": "* @acme/admins\n", "dependabot.yml": "version: 2\n"})
+		status, _, err = syncRepo(context.Background(), client, target, updated, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(status, "updated pull request #1") || len(gh.pulls) != 1 {
+			t.Errorf("syncRepo = %q with %d pull requests; want updated pull request #1", status, len(gh.pulls))
+		}
+		if got := gh.files(opts.branch)[".github/CODEOWNERS"]; got != "* @acme/admins\n" {
+			t.Errorf("%s CODEOWNERS = %q after update", opts.branch, got)
+		}
+	})
+
+	t.Run("manifest deletes skip edited files", func(t *testing.T) {
+		// The last sync also generated two files whose templates are gone
+		previous := syncFiles(t, map[string]string{
+			"CODEOWNERS":          "* @acme/owners\n",
+			"dependabot.yml":      "version: 2\n",
+			"workflows/old.yml":   "name: old\n",
+			"workflows/tuned.yml": "name: tuned\n",
+		})
+		repo := repoFiles(previous)
+		repo[".github/workflows/tuned.yml"] = "name: tuned by hand\n"
+		gh, client := newFakeGitHub(t, repo)
+
+		if _, _, err := syncRepo(context.Background(), client, target, files, opts); err != nil {
+			t.Fatal(err)
+		}
+		got := gh.files(opts.branch)
+		if _, ok := got[".github/workflows/old.yml"]; ok {
+			t.Error("unchanged stale workflows/old.yml was not deleted")
+		}
+		if got[".github/workflows/tuned.yml"] != "name: tuned by hand\n" {
+			t.Errorf("edited stale workflows/tuned.yml = %q, want it kept", got[".github/workflows/tuned.yml"])
+		}
+		if got[".github/"+manifestName] != repoFiles(files)[".github/"+manifestName] {
+			t.Errorf("manifest not updated:\n%s", got[".github/"+manifestName])
+		}
+	})
+}
+
+func TestCreateCommit(t *testing.T) {
+	gh, client := newFakeGitHub(t, map[string]string{"keep.txt": "keep\n", "old.txt": "old\n"})
+	ctx := context.Background()
+
+	parent := gh.branches["main"]
+	baseTree, _, err := client.Tree(ctx, "acme", "widgets", parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := client.CreateCommit(ctx, "acme", "widgets", parent, baseTree, "chore: sync",
+		map[string][]byte{"new.txt": []byte("new\n"), "keep.txt": []byte("kept\n")}, []string{"old.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetBranch(ctx, "acme", "widgets", "sync", commit, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"keep.txt": "kept\n", "new.txt": "new\n"}
+	if got := gh.files("sync"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("commit holds %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Known keys and values of the file formats validateFiles checks, following
+// the GitHub Actions, Dependabot and issue form documentation
+var (
+	workflowKeys = keySet("name", "run-name", "on", "permissions", "env", "defaults", "concurrency", "jobs")
+	jobKeys      = keySet("name", "needs", "runs-on", "permissions", "environment", "concurrency", "outputs", "env",
+		"defaults", "if", "steps", "timeout-minutes", "strategy", "continue-on-error", "container", "services",
+		"uses", "with", "secrets")
+	stepKeys = keySet("id", "if", "name", "uses", "run", "shell", "with", "env", "continue-on-error",
+		"timeout-minutes", "working-directory")
+	workflowEvents = keySet("branch_protection_rule", "check_run", "check_suite", "create", "delete", "deployment",
+		"deployment_status", "discussion", "discussion_comment", "fork", "gollum", "issue_comment", "issues",
+		"label", "merge_group", "milestone", "page_build", "public", "pull_request", "pull_request_review",
+		"pull_request_review_comment", "pull_request_target", "push", "registry_package", "release",
+		"repository_dispatch", "schedule", "status", "watch", "workflow_call", "workflow_dispatch", "workflow_run")
+	callInputTypes     = keySet("boolean", "number", "string")
+	dispatchInputTypes = keySet("boolean", "number", "string", "choice", "environment")
+
+	dependabotKeys       = keySet("version", "updates", "registries", "enable-beta-ecosystems", "multi-ecosystem-groups")
+	dependabotUpdateKeys = keySet("package-ecosystem", "directory", "directories", "schedule", "allow", "assignees",
+		"commit-message", "cooldown", "exclude-paths", "groups", "ignore", "insecure-external-code-execution", "labels",
+		"milestone", "multi-ecosystem-group", "open-pull-requests-limit", "patterns", "pull-request-branch-name",
+		"rebase-strategy", "registries", "reviewers", "target-branch", "vendor", "versioning-strategy")
+	dependabotEcosystems = keySet("bun", "bundler", "cargo", "composer", "devcontainers", "docker", "docker-compose",
+		"dotnet-sdk", "elm", "github-actions", "gitsubmodule", "gomod", "gradle", "helm", "maven", "mix", "npm",
+		"nuget", "pip", "pub", "swift", "terraform", "uv")
+	dependabotIntervals = keySet("daily", "weekly", "monthly", "quarterly", "semiannually", "yearly", "cron")
+
+	issueFormKeys       = keySet("name", "description", "title", "labels", "assignees", "projects", "type", "body")
+	issueFormItemKeys   = keySet("type", "id", "attributes", "validations")
+	issueFormItemTypes  = keySet("markdown", "textarea", "input", "dropdown", "checkboxes")
+	issueFormValidators = keySet("required")
+
+	jobIDPattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	actionRefPattern  = regexp.MustCompile(`^[^/@\s]+/[^@\s]+@\S+$`)
+	formIDPattern     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	ownerPattern      = regexp.MustCompile(`^@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:/[A-Za-z0-9._-]+)?$`)
+	ownerEmailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// checker collects the problems found in one file
+type checker struct {
+	path     string
+	problems []string
+}
+
+// errorf records a problem at the line of node, or of the whole file when node is nil
+func (c *checker) errorf(node *yaml.Node, format string, args ...interface{}) {
+	line := 0
+	if node != nil {
+		line = node.Line
+	}
+	c.problemf(line, format, args...)
+}
+
+// problemf records a problem at a line, 0 for the whole file
+func (c *checker) problemf(line int, format string, args ...interface{}) {
+	location := c.path
+	if line > 0 {
+		location = fmt.Sprintf("%s:%d", c.path, line)
+	}
+	c.problems = append(c.problems, location+": "+fmt.Sprintf(format, args...))
+}
+
+// validateFiles checks each rendered file according to its type and returns
+// every problem found as "path:line: message"
+func validateFiles(files []renderedFile) []string {
+	var problems []string
+	for _, file := range files {
+		c := &checker{path: filepath.ToSlash(file.Path)}
+		validateFile(c, file.Content)
+		problems = append(problems, c.problems...)
+	}
+	return problems
+}
+
+// validateFile dispatches on the file's location in the output directory
+func validateFile(c *checker, content []byte) {
+	dir, base := path.Dir(c.path), path.Base(c.path)
+
+	if base == "CODEOWNERS" {
+		validateCodeowners(c, content)
+		return
+	}
+
+	ext := path.Ext(base)
+	if ext != ".yml" && ext != ".yaml" {
+		return
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		c.errorf(nil, "invalid YAML: %v", err)
+		return
+	}
+	if len(doc.Content) == 0 {
+		c.errorf(nil, "empty YAML document")
+		return
+	}
+	root := doc.Content[0]
+
+	switch {
+	case dir == "workflows":
+		validateWorkflow(c, root)
+	case dir == "." && strings.TrimSuffix(base, ext) == "dependabot":
+		validateDependabot(c, root)
+	case (dir == "ISSUE_TEMPLATE" || dir == "issue-templates") && strings.TrimSuffix(base, ext) != "config":
+		validateIssueForm(c, root)
+	}
+}
+
+// validateWorkflow checks the structure of a GitHub Actions workflow
+func validateWorkflow(c *checker, root *yaml.Node) {
+	fields := c.mapping(root, "workflow", workflowKeys)
+	if fields == nil {
+		return
+	}
+
+	if on, ok := fields["on"]; !ok {
+		c.errorf(root, "workflow has no on: triggers")
+	} else {
+		validateTriggers(c, on)
+	}
+
+	jobs, ok := fields["jobs"]
+	if !ok {
+		c.errorf(root, "workflow has no jobs")
+		return
+	}
+	jobFields := c.mapping(jobs, "jobs", nil)
+	if jobFields == nil {
+		return
+	}
+	if len(jobFields) == 0 {
+		c.errorf(jobs, "workflow has no jobs")
+	}
+
+	for _, id := range sortedNodeKeys(jobFields) {
+		job := jobFields[id]
+		if !jobIDPattern.MatchString(id) {
+			c.errorf(job, "invalid job id %q: use letters, digits, - and _, starting with a letter or _", id)
+		}
+		validateJob(c, id, job, jobFields)
+	}
+}
+
+// validateTriggers checks on: as an event name, a list of them or a map of event settings
+func validateTriggers(c *checker, on *yaml.Node) {
+	switch on.Kind {
+	case yaml.ScalarNode:
+		c.knownValue(on, "event", workflowEvents)
+	case yaml.SequenceNode:
+		for _, event := range on.Content {
+			c.knownValue(event, "event", workflowEvents)
+		}
+	case yaml.MappingNode:
+		events := c.mapping(on, "on", workflowEvents)
+		for _, trigger := range []struct {
+			name      string
+			inputType map[string]bool
+		}{{"workflow_call", callInputTypes}, {"workflow_dispatch", dispatchInputTypes}} {
+			name, inputType := trigger.name, trigger.inputType
+			event, ok := events[name]
+			if !ok || event.Kind != yaml.MappingNode {
+				continue
+			}
+			inputs, ok := c.fields(event)["inputs"]
+			if !ok {
+				continue
+			}
+			specs := c.mapping(inputs, name+" inputs", nil)
+			for _, input := range sortedNodeKeys(specs) {
+				spec := specs[input]
+				if t, ok := c.fields(spec)["type"]; ok {
+					c.knownValue(t, name+" input "+input+" type", inputType)
+				} else if name == "workflow_call" {
+					c.errorf(spec, "workflow_call input %s has no type", input)
+				}
+			}
+		}
+	default:
+		c.errorf(on, "on: must be an event, a list of events or a map")
+	}
+}
+
+// validateJob checks one job and its steps
+func validateJob(c *checker, id string, job *yaml.Node, jobs map[string]*yaml.Node) {
+	fields := c.mapping(job, "job "+id, jobKeys)
+	if fields == nil {
+		return
+	}
+
+	if needs, ok := fields["needs"]; ok {
+		list := []*yaml.Node{needs}
+		if needs.Kind == yaml.SequenceNode {
+			list = needs.Content
+		}
+		for _, need := range list {
+			if _, ok := jobs[need.Value]; !ok {
+				c.errorf(need, "job %s needs unknown job %q", id, need.Value)
+			}
+		}
+	}
+
+	// A job either calls a reusable workflow or runs steps on a runner
+	if uses, ok := fields["uses"]; ok {
+		if _, ok := fields["steps"]; ok {
+			c.errorf(job, "job %s has both uses and steps", id)
+		}
+		validateUses(c, uses)
+		return
+	}
+	if _, ok := fields["runs-on"]; !ok {
+		c.errorf(job, "job %s has no runs-on", id)
+	}
+	steps, ok := fields["steps"]
+	if !ok {
+		c.errorf(job, "job %s has no steps", id)
+		return
+	}
+	if steps.Kind != yaml.SequenceNode || len(steps.Content) == 0 {
+		c.errorf(steps, "job %s steps must be a non-empty list", id)
+		return
+	}
+
+	for i, step := range steps.Content {
+		name := fmt.Sprintf("job %s step %d", id, i+1)
+		stepFields := c.mapping(step, name, stepKeys)
+		if stepFields == nil {
+			continue
+		}
+		uses, hasUses := stepFields["uses"]
+		_, hasRun := stepFields["run"]
+		switch {
+		case hasUses && hasRun:
+			c.errorf(step, "%s has both uses and run", name)
+		case !hasUses && !hasRun:
+			c.errorf(step, "%s has neither uses nor run", name)
+		case hasUses:
+			validateUses(c, uses)
+		}
+	}
+}
+
+// validateUses checks an action or reusable workflow reference
+func validateUses(c *checker, uses *yaml.Node) {
+	ref := uses.Value
+	if strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "docker://") {
+		return
+	}
+	if !actionRefPattern.MatchString(ref) {
+		c.errorf(uses, "invalid uses %q: expected owner/repo@ref, ./path or docker://image", ref)
+	}
+}
+
+// validateDependabot checks a Dependabot version 2 configuration
+func validateDependabot(c *checker, root *yaml.Node) {
+	fields := c.mapping(root, "dependabot config", dependabotKeys)
+	if fields == nil {
+		return
+	}
+
+	if version, ok := fields["version"]; !ok {
+		c.errorf(root, "dependabot config must have version: 2")
+	} else if version.Value != "2" {
+		c.errorf(version, "unsupported dependabot config version %s: expected 2", version.Value)
+	}
+
+	updates, ok := fields["updates"]
+	if !ok || updates.Kind != yaml.SequenceNode || len(updates.Content) == 0 {
+		c.errorf(root, "dependabot config needs a non-empty updates list")
+		return
+	}
+
+	seen := make(map[string]bool)
+	for i, update := range updates.Content {
+		name := fmt.Sprintf("update %d", i+1)
+		updateFields := c.mapping(update, name, dependabotUpdateKeys)
+		if updateFields == nil {
+			continue
+		}
+
+		ecosystem, ok := updateFields["package-ecosystem"]
+		if !ok {
+			c.errorf(update, "%s has no package-ecosystem", name)
+		} else {
+			c.knownValue(ecosystem, "package-ecosystem", dependabotEcosystems)
+		}
+
+		directory, hasDirectory := updateFields["directory"]
+		_, hasDirectories := updateFields["directories"]
+		switch {
+		case hasDirectory && hasDirectories:
+			c.errorf(update, "%s has both directory and directories", name)
+		case !hasDirectory && !hasDirectories:
+			c.errorf(update, "%s has no directory", name)
+		}
+		if ecosystem != nil && directory != nil {
+			key := ecosystem.Value + " " + directory.Value
+			if seen[key] {
+				c.errorf(update, "%s repeats package-ecosystem %s for directory %s", name, ecosystem.Value, directory.Value)
+			}
+			seen[key] = true
+		}
+
+		schedule, ok := updateFields["schedule"]
+		if !ok {
+			if _, grouped := updateFields["multi-ecosystem-group"]; !grouped {
+				c.errorf(update, "%s has no schedule", name)
+			}
+			continue
+		}
+		if interval, ok := c.fields(schedule)["interval"]; !ok {
+			c.errorf(schedule, "%s schedule has no interval", name)
+		} else {
+			c.knownValue(interval, "schedule interval", dependabotIntervals)
+		}
+	}
+}
+
+// validateIssueForm checks a GitHub issue form
+func validateIssueForm(c *checker, root *yaml.Node) {
+	fields := c.mapping(root, "issue form", issueFormKeys)
+	if fields == nil {
+		return
+	}
+
+	for _, key := range []string{"name", "description"} {
+		if value, ok := fields[key]; !ok || strings.TrimSpace(value.Value) == "" {
+			c.errorf(root, "issue form has no %s", key)
+		}
+	}
+
+	body, ok := fields["body"]
+	if !ok || body.Kind != yaml.SequenceNode || len(body.Content) == 0 {
+		c.errorf(root, "issue form needs a non-empty body list")
+		return
+	}
+
+	ids := make(map[string]bool)
+	inputs := 0
+	for i, item := range body.Content {
+		name := fmt.Sprintf("body item %d", i+1)
+		itemFields := c.mapping(item, name, issueFormItemKeys)
+		if itemFields == nil {
+			continue
+		}
+
+		kind, ok := itemFields["type"]
+		if !ok {
+			c.errorf(item, "%s has no type", name)
+			continue
+		}
+		if !c.knownValue(kind, "body item type", issueFormItemTypes) {
+			continue
+		}
+		if kind.Value != "markdown" {
+			inputs++
+		}
+
+		if id, ok := itemFields["id"]; ok {
+			if !formIDPattern.MatchString(id.Value) {
+				c.errorf(id, "invalid id %q: use letters, digits, - and _", id.Value)
+			}
+			if ids[id.Value] {
+				c.errorf(id, "duplicate id %q", id.Value)
+			}
+			ids[id.Value] = true
+		}
+
+		if validations, ok := itemFields["validations"]; ok {
+			if kind.Value == "markdown" {
+				c.errorf(validations, "%s: markdown cannot have validations", name)
+			}
+			c.mapping(validations, name+" validations", issueFormValidators)
+		}
+
+		attributes, ok := itemFields["attributes"]
+		if !ok {
+			c.errorf(item, "%s has no attributes", name)
+			continue
+		}
+		attrs := c.fields(attributes)
+		required := "label"
+		if kind.Value == "markdown" {
+			required = "value"
+		}
+		if value, ok := attrs[required]; !ok || strings.TrimSpace(value.Value) == "" {
+			c.errorf(attributes, "%s (%s) has no %s", name, kind.Value, required)
+		}
+		if kind.Value == "dropdown" || kind.Value == "checkboxes" {
+			if options, ok := attrs["options"]; !ok || options.Kind != yaml.SequenceNode || len(options.Content) == 0 {
+				c.errorf(attributes, "%s (%s) needs a non-empty options list", name, kind.Value)
+			}
+		}
+	}
+
+	if inputs == 0 {
+		c.errorf(body, "issue form needs at least one field besides markdown")
+	}
+}
+
+// validateCodeowners checks CODEOWNERS patterns and owners
+func validateCodeowners(c *checker, content []byte) {
+	for i, line := range strings.Split(string(content), "\n") {
+		if comment := unescapedHash(line); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		pattern := fields[0]
+		switch {
+		case strings.HasPrefix(pattern, "!"):
+			c.problemf(i+1, "negated pattern %q is not supported in CODEOWNERS", pattern)
+		case strings.ContainsAny(pattern, "[]"):
+			c.problemf(i+1, "character range in %q is not supported in CODEOWNERS", pattern)
+		case strings.HasPrefix(pattern, "@"):
+			c.problemf(i+1, "line starts with owner %q instead of a pattern", pattern)
+		}
+
+		for _, owner := range fields[1:] {
+			if !ownerPattern.MatchString(owner) && !ownerEmailPattern.MatchString(owner) {
+				c.problemf(i+1, "invalid owner %q: expected @user, @org/team or an email address", owner)
+			}
+		}
+	}
+}
+
+// unescapedHash returns the index of the first # not preceded by \, or -1
+func unescapedHash(line string) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '#' {
+			return i
+		}
+	}
+	return -1
+}
+
+// fields returns the values of a mapping node by key, or nil for other nodes
+func (c *checker) fields(node *yaml.Node) map[string]*yaml.Node {
+	node = resolve(node)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	fields := make(map[string]*yaml.Node, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fields[node.Content[i].Value] = resolve(node.Content[i+1])
+	}
+	return fields
+}
+
+// mapping returns the values of a mapping node, reporting keys not in known
+// (when given) and nodes that are not mappings
+func (c *checker) mapping(node *yaml.Node, name string, known map[string]bool) map[string]*yaml.Node {
+	node = resolve(node)
+	if node.Kind != yaml.MappingNode {
+		c.errorf(node, "%s must be a map", name)
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if known != nil && !known[key.Value] {
+			c.errorf(key, "unknown key %q in %s", key.Value, name)
+		}
+	}
+	return c.fields(node)
+}
+
+// knownValue reports whether a scalar is one of known, recording a problem if not
+func (c *checker) knownValue(node *yaml.Node, name string, known map[string]bool) bool {
+	if known[node.Value] {
+		return true
+	}
+	c.errorf(node, "unknown %s %q", name, node.Value)
+	return false
+}
+
+// resolve follows YAML aliases to the node they refer to
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// keySet builds a set of strings
+func keySet(keys ...string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+// sortedNodeKeys returns the keys of m in order, for stable messages
+func sortedNodeKeys(m map[string]*yaml.Node) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidateFiles(t *testing.T) {
+	const workflow = `name: CI
+on: [push, pull_request]
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - run: go test ./...
+`
+	const dependabot = `version: 2
+updates:
+  - package-ecosystem: gomod
+    directory: /
+    schedule:
+      interval: weekly
+`
+	const issueForm = `name: Bug report
+description: Report a bug
+body:
+  - type: markdown
+    attributes:
+      value: Thanks!
+  - type: textarea
+    id: what
+    attributes:
+      label: What happened?
+    validations:
+      required: true
+`
+
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    []string
+	}{
+		{"valid workflow", "workflows/ci.yml", workflow, nil},
+		{"valid dependabot", "dependabot.yml", dependabot, nil},
+		{"valid issue form", "issue-templates/bug.yml", issueForm, nil},
+		{"issue template config", "ISSUE_TEMPLATE/config.yml", "blank_issues_enabled: false\n", nil},
+		{"valid CODEOWNERS", "CODEOWNERS", "# owners\n* @acme/owners dev@acme.io\n/docs/ @writer # docs\n", nil},
+		{"other files are not checked", "README.md", "{{ not yaml", nil},
+		{"other YAML is only parsed", "labels.yml", "anything: goes\n", nil},
+
+		{"invalid YAML", "workflows/ci.yml", "on: [push\n", []string{
+			"workflows/ci.yml: invalid YAML: yaml: line 1: did not find expected ',' or ']'"}},
+		{"empty YAML", "dependabot.yml", "", []string{
+			"dependabot.yml: empty YAML document"}},
+		{"workflow without triggers and jobs", "workflows/ci.yml", "name: CI\nrun: true\n", []string{
+			`workflows/ci.yml:2: unknown key "run" in workflow`,
+			"workflows/ci.yml:1: workflow has no on: triggers",
+			"workflows/ci.yml:1: workflow has no jobs"}},
+		{"workflow job problems", "workflows/ci.yml", `on: pushed
+jobs:
+  1st:
+    runs-on: ubuntu-latest
+    needs: build
+    steps:
+      - uses: checkout
+      - name: nothing
+  call:
+    uses: acme/.github/.github/workflows/go.yml@main
+    steps:
+      - run: echo
+  idle:
+    runs-on: ubuntu-latest
+`, []string{
+			`workflows/ci.yml:1: unknown event "pushed"`,
+			`workflows/ci.yml:4: invalid job id "1st": use letters, digits, - and _, starting with a letter or _`,
+			`workflows/ci.yml:5: job 1st needs unknown job "build"`,
+			`workflows/ci.yml:7: invalid uses "checkout": expected owner/repo@ref, ./path or docker://image`,
+			"workflows/ci.yml:8: job 1st step 2 has neither uses nor run",
+			"workflows/ci.yml:10: job call has both uses and steps",
+			"workflows/ci.yml:14: job idle has no steps"}},
+		{"workflow_call input without type", "workflows/reusable.yml", `on:
+  workflow_call:
+    inputs:
+      version:
+        required: true
+      mode:
+        type: choice
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo
+`, []string{
+			"workflows/reusable.yml:7: unknown workflow_call input mode type \"choice\"",
+			"workflows/reusable.yml:5: workflow_call input version has no type"}},
+		{"dependabot problems", "dependabot.yml", `version: 1
+updates:
+  - package-ecosystem: gomodules
+    directory: /
+    schedule:
+      interval: hourly
+  - package-ecosystem: npm
+    directory: /
+    directories: [/web]
+  - package-ecosystem: npm
+    directory: /
+    schedule: {}
+`, []string{
+			"dependabot.yml:1: unsupported dependabot config version 1: expected 2",
+			`dependabot.yml:3: unknown package-ecosystem "gomodules"`,
+			`dependabot.yml:6: unknown schedule interval "hourly"`,
+			"dependabot.yml:7: update 2 has both directory and directories",
+			"dependabot.yml:7: update 2 has no schedule",
+			"dependabot.yml:10: update 3 repeats package-ecosystem npm for directory /",
+			"dependabot.yml:12: update 3 schedule has no interval"}},
+		{"issue form problems", "issue-templates/bug.yml", `name: Bug
+body:
+  - type: markdown
+    attributes:
+      value: Hi
+    validations:
+      required: true
+  - type: dropdown
+    id: area
+    attributes:
+      label: Area
+  - type: input
+    id: area
+    attributes: {}
+  - type: slider
+`, []string{
+			"issue-templates/bug.yml:1: issue form has no description",
+			"issue-templates/bug.yml:7: body item 1: markdown cannot have validations",
+			"issue-templates/bug.yml:11: body item 2 (dropdown) needs a non-empty options list",
+			`issue-templates/bug.yml:13: duplicate id "area"`,
+			"issue-templates/bug.yml:14: body item 3 (input) has no label",
+			`issue-templates/bug.yml:15: unknown body item type "slider"`}},
+		{"issue form with only markdown", "issue-templates/note.yml", `name: Note
+description: A note
+body:
+  - type: markdown
+    attributes:
+      value: Hi
+`, []string{
+			"issue-templates/note.yml:4: issue form needs at least one field besides markdown"}},
+		{"CODEOWNERS problems", "CODEOWNERS", "!vendor/ @acme/owners\n*.[ch] @acme/c\n@acme/owners\ndocs/ acme/writers\n", []string{
+			`CODEOWNERS:1: negated pattern "!vendor/" is not supported in CODEOWNERS`,
+			`CODEOWNERS:2: character range in "*.[ch]" is not supported in CODEOWNERS`,
+			`CODEOWNERS:3: line starts with owner "@acme/owners" instead of a pattern`,
+			`CODEOWNERS:4: invalid owner "acme/writers": expected @user, @org/team or an email address`}},
+		{"escaped hash in CODEOWNERS", "CODEOWNERS", "\\#notes/ @acme/owners # comment\n", nil},
+	}
+	for _, tt := range tests {
+		got := validateFiles([]renderedFile{{Path: tt.path, Content: []byte(tt.content)}})
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("%s: validateFiles =\n  %q\nwant\n  %q", tt.name, got, tt.want)
+		}
+	}
+}
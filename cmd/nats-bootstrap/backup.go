@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/embeddednats"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// backupTimeFormat names backups like the terraform backup CronJob does
+const backupTimeFormat = "20060102_150405"
+
+// clientFlags are the connection settings of the backup and restore
+// subcommands, defaulting to the variables printed by nats-bootstrap on start
+type clientFlags struct {
+	url      string
+	creds    string
+	caFile   string
+	certFile string
+	keyFile  string
+	domain   string
+}
+
+// addClientFlags registers the connection flags on fs
+func addClientFlags(fs *flag.FlagSet) *clientFlags {
+	c := &clientFlags{}
+	fs.StringVar(&c.url, "server", envOr("NATS_URL", nats.DefaultURL), "NATS server URL")
+	fs.StringVar(&c.creds, "creds", os.Getenv("NATS_CREDS_FILE"), "Credentials file")
+	fs.StringVar(&c.caFile, "tls-ca", os.Getenv("NATS_TLS_CA_FILE"), "CA certificate to trust")
+	fs.StringVar(&c.certFile, "tls-cert", os.Getenv("NATS_TLS_CERT_FILE"), "Client certificate for mutual TLS")
+	fs.StringVar(&c.keyFile, "tls-key", os.Getenv("NATS_TLS_KEY_FILE"), "Client key for mutual TLS")
+	fs.StringVar(&c.domain, "domain", os.Getenv("NATS_JETSTREAM_DOMAIN"), "JetStream domain")
+	return c
+}
+
+// connect opens a connection and JetStream context with the flag settings
+func (c *clientFlags) connect(name string) (*nats.Conn, jetstream.JetStream, error) {
+	opts := []nats.Option{nats.Name(name)}
+	if c.creds != "" {
+		opts = append(opts, nats.UserCredentials(c.creds))
+	}
+	if c.caFile != "" {
+		opts = append(opts, nats.RootCAs(c.caFile))
+	}
+	if c.certFile != "" && c.keyFile != "" {
+		opts = append(opts, nats.ClientCert(c.certFile, c.keyFile))
+	}
+
+	nc, err := nats.Connect(c.url, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to %s: %w", c.url, err)
+	}
+
+	var js jetstream.JetStream
+	if c.domain != "" {
+		js, err = jetstream.NewWithDomain(nc, c.domain)
+	} else {
+		js, err = jetstream.New(nc)
+	}
+	if err != nil {
+		nc.Close()
+		return nil, nil, fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	return nc, js, nil
+}
+
+// runBackup implements the "backup" subcommand
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	client := addClientFlags(fs)
+	streams := fs.String("streams", "", "Comma-separated streams to back up (default: all, including KV buckets and object stores)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: nats-bootstrap backup [flags] [destination]\n\n")
+		fmt.Fprintf(fs.Output(), "The destination is a directory (one `nats stream backup` directory per stream),\n")
+		fmt.Fprintf(fs.Output(), "a .tar.gz file or s3://bucket/key; a trailing / adds a timestamped .tar.gz name.\n")
+		fmt.Fprintf(fs.Output(), "Default: nats-backup-<timestamp>.tar.gz\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	name := "nats-backup-" + time.Now().UTC().Format(backupTimeFormat)
+	dest := fs.Arg(0)
+	if dest == "" {
+		dest = name + ".tar.gz"
+	}
+	if strings.HasSuffix(dest, "/") {
+		dest += name + ".tar.gz"
+	}
+
+	nc, js, err := client.connect("nats-bootstrap-backup")
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	dir := dest
+	if isS3URL(dest) || isArchive(dest) {
+		tempDir, err := os.MkdirTemp("", "nats-backup-*")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+		dir = filepath.Join(tempDir, name)
+	}
+
+	var names []string
+	if *streams != "" {
+		names = strings.Split(*streams, ",")
+	}
+
+	backups, err := embeddednats.BackupStreams(ctx, js, dir, names...)
+	for _, backup := range backups {
+		log.Printf("💾 %s: %d messages, %d bytes", backup.Stream, backup.Messages, backup.Bytes)
+	}
+	if err != nil {
+		return err
+	}
+
+	if dir != dest {
+		if err := packBackup(dir, dest); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Backed up %d stream(s) to %s", len(backups), dest)
+	return nil
+}
+
+// runRestore implements the "restore" subcommand
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	client := addClientFlags(fs)
+	replace := fs.Bool("replace", false, "Delete streams that already exist before restoring them")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: nats-bootstrap restore [flags] <source>\n\n")
+		fmt.Fprintf(fs.Output(), "The source is a backup directory, a .tar.gz file or s3://bucket/key.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("restore needs exactly one source")
+	}
+
+	dir, cleanup, err := fetchBackup(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	nc, js, err := client.connect("nats-bootstrap-restore")
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	restored, err := embeddednats.RestoreStreams(ctx, js, dir, *replace)
+	for _, backup := range restored {
+		log.Printf("♻️ %s: %d messages", backup.Stream, backup.Messages)
+	}
+	if err != nil {
+		if errors.Is(err, embeddednats.ErrStreamExists) {
+			return fmt.Errorf("%w (use -replace to overwrite)", err)
+		}
+		return err
+	}
+
+	log.Printf("✅ Restored %d stream(s) from %s", len(restored), fs.Arg(0))
+	return nil
+}
+
+// packBackup archives a backup directory to a .tar.gz file or S3 object
+func packBackup(dir, dest string) error {
+	archive := dest
+	if isS3URL(dest) {
+		archive = dir + ".tar.gz"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archive), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(archive), err)
+	}
+	f, err := os.Create(archive)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", archive, err)
+	}
+	if err := embeddednats.WriteBackupArchive(f, dir); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", archive, err)
+	}
+
+	if !isS3URL(dest) {
+		return nil
+	}
+
+	obj, err := parseS3URL(dest)
+	if err != nil {
+		return err
+	}
+	log.Printf("☁️ Uploading to %s via %s", obj, obj.Endpoint.Host)
+	return obj.Put(archive)
+}
+
+// fetchBackup makes a backup available as a local directory, downloading
+// and extracting it when needed; cleanup removes anything it created
+func fetchBackup(source string) (string, func(), error) {
+	if !isS3URL(source) && !isArchive(source) {
+		return source, func() {}, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "nats-restore-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	archive := source
+	if isS3URL(source) {
+		obj, err := parseS3URL(source)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		log.Printf("☁️ Downloading %s via %s", obj, obj.Endpoint.Host)
+		archive = filepath.Join(tempDir, "backup.tar.gz")
+		if err := obj.Get(archive); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+
+	dir := filepath.Join(tempDir, "backup")
+	if err := embeddednats.ExtractBackupArchive(f, dir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return dir, cleanup, nil
+}
+
+// isArchive reports whether a backup location is a gzipped tar file
+func isArchive(location string) bool {
+	return strings.HasSuffix(location, ".tar.gz") || strings.HasSuffix(location, ".tgz")
+}
+
+// envOr returns the environment variable name, or fallback when it is unset
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
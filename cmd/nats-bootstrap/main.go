@@ -1,225 +1,208 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
 	"syscall"
-	"time"
 
-	"github.com/nats-io/nats-server/v2/server"
-	"github.com/nats-io/nats.go"
+	"github.com/joeblew999/.github/pkg/embeddednats"
 )
 
 const version = "1.0.0"
 
-// EmbeddedNATS provides a simple embedded NATS server for bootstrap/development
-type EmbeddedNATS struct {
-	server  *server.Server
-	opts    *server.Options
-	tempDir string
-}
-
-// NewEmbeddedNATS creates a new embedded NATS server
-func NewEmbeddedNATS() (*EmbeddedNATS, error) {
-	// Create temporary directory for JetStream storage
-	tempDir, err := os.MkdirTemp("", "nats-bootstrap-*")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
-	}
-
-	opts := &server.Options{
-		Host:     "127.0.0.1",
-		Port:     4222,
-		HTTPHost: "127.0.0.1",
-		HTTPPort: 8222,
-
-		// JetStream configuration
-		JetStream: true,
-		StoreDir:  filepath.Join(tempDir, "jetstream"),
-
-		// Logging
-		Debug:   false,
-		Trace:   false,
-		Logtime: true,
-		NoLog:   false,
-
-		// Cluster name for development
-		ServerName: "nats-bootstrap",
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			if err := runBackup(os.Args[2:]); err != nil {
+				log.Fatalf("Backup failed: %v", err)
+			}
+			return
+		case "restore":
+			if err := runRestore(os.Args[2:]); err != nil {
+				log.Fatalf("Restore failed: %v", err)
+			}
+			return
+		}
 	}
 
-	s, err := server.NewServer(opts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create NATS server: %w", err)
+	if err := run(); err != nil {
+		log.Fatalf("Bootstrap failed: %v", err)
 	}
-
-	return &EmbeddedNATS{
-		server:  s,
-		opts:    opts,
-		tempDir: tempDir,
-	}, nil
 }
 
-// Start starts the embedded NATS server
-func (e *EmbeddedNATS) Start() error {
-	log.Printf("🚀 Starting embedded NATS server v%s", version)
-	log.Printf("   Server: %s:%d", e.opts.Host, e.opts.Port)
-	log.Printf("   HTTP Monitor: %s:%d", e.opts.HTTPHost, e.opts.HTTPPort)
-	log.Printf("   JetStream Store: %s", e.opts.StoreDir)
+// run starts the server and blocks until it is stopped; returning errors
+// instead of exiting lets deferred cleanup, such as a fetched backup, run
+func run() error {
+	cfg := embeddednats.DefaultOptions()
+	flag.StringVar(&cfg.Host, "host", cfg.Host, "Interface to listen on")
+	flag.IntVar(&cfg.Port, "port", cfg.Port, "Client port (0 for random)")
+	flag.IntVar(&cfg.HTTPPort, "http-port", cfg.HTTPPort, "HTTP monitoring port (0 for random, -1 to disable)")
+	flag.StringVar(&cfg.StoreDir, "store-dir", cfg.StoreDir, "Persistent JetStream store directory (default: temp dir removed on exit)")
+	flag.StringVar(&cfg.ServerName, "server-name", cfg.ServerName, "Server name")
+	flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Server log level: none, info, debug or trace")
+	flag.BoolVar(&cfg.Auth, "auth", cfg.Auth, "Generate operator/account/user credentials in the store dir and require them")
+	flag.BoolVar(&cfg.TLS, "tls", cfg.TLS, "Generate a dev CA and certificates in the store dir and serve TLS")
+	flag.BoolVar(&cfg.MTLS, "mtls", cfg.MTLS, "Like -tls, and require client certificates")
+	flag.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "nats-server config file (listen, auth and monitoring come from the file)")
+	flag.StringVar(&cfg.CredsFile, "creds", cfg.CredsFile, "Credentials used to provision streams when -config enables auth")
+	flag.StringVar(&cfg.Domain, "domain", cfg.Domain, "JetStream domain of this server (default \"leaf\" with -leaf)")
+	flag.StringVar(&cfg.LeafURL, "leaf", cfg.LeafURL, "Run as a leafnode of this hub URL, e.g. tls://connect.ngs.global:7422")
+	flag.StringVar(&cfg.LeafCredsFile, "leaf-creds", cfg.LeafCredsFile, "Credentials for the hub connection")
+	flag.StringVar(&cfg.HubDomain, "hub-domain", cfg.HubDomain, "JetStream domain of the hub")
+	flag.StringVar(&cfg.HubStream, "hub-stream", cfg.HubStream, "Events stream on the hub GITHUB_EVENTS follows; must not have work-queue retention (default GITHUB_EVENTS)")
+	flag.StringVar(&cfg.EventsSync, "events-sync", cfg.EventsSync, "How GITHUB_EVENTS follows the hub: source (catches up after offline) or mirror (read-only)")
+	flag.BoolVar(&cfg.EventsUpstream, "events-upstream", cfg.EventsUpstream, "Keep events published on the leafnode in GITHUB_EVENTS_UPSTREAM for the hub to source (start the hub with -leaf-domains <-domain>)")
+	flag.IntVar(&cfg.LeafListenPort, "leaf-port", cfg.LeafListenPort, "Accept leafnode connections on this port (0 disables)")
+	leafDomains := flag.String("leaf-domains", "", "Comma-separated JetStream domains of -events-upstream leafnodes whose events GITHUB_EVENTS sources")
+	flag.StringVar(&cfg.SpecFile, "spec", cfg.SpecFile, "YAML or JSON spec of streams, consumers, KV buckets and object stores (default: built-in)")
+	flag.StringVar(&cfg.SeedDir, "seed", cfg.SeedDir, "Directory of NDJSON event fixtures to publish after startup (skipped when their streams already hold messages)")
+	flag.Float64Var(&cfg.SeedSpeed, "seed-speed", cfg.SeedSpeed, "Replay the gaps between seed event timestamps at this speed-up (0 publishes at once)")
+	flag.IntVar(&cfg.StatusPort, "status-port", cfg.StatusPort, "Readiness (/readyz) and health (/health) HTTP port (0 for random, -1 to disable)")
+	flag.StringVar(&cfg.Org, "org", os.Getenv("GITHUB_ORG"), "GitHub organization to publish NATSHealthEvent messages for on system.<org>.nats_health")
+	flag.DurationVar(&cfg.HealthInterval, "health-interval", cfg.HealthInterval, "How often to publish health events (0 disables)")
+	restore := flag.String("restore", "", "Restore streams from a backup directory, .tar.gz or s3:// URL before provisioning")
+	flag.BoolVar(&cfg.RestoreReplace, "restore-replace", cfg.RestoreReplace, "Delete streams in -store-dir that -restore has backups of, instead of keeping them")
+	clusterSize := flag.Int("cluster", 1, "Number of clustered servers; node i uses port+i, http-port+i and cluster-port+i")
+	clusterPort := flag.Int("cluster-port", embeddednats.DefaultClusterPort, "Route port of the first cluster node (0 for random)")
+	flag.Parse()
 
-	// Start the server
-	go e.server.Start()
+	log.Printf("🤖 NATS Bootstrap Server v%s", version)
 
-	// Wait for server to be ready
-	if !e.server.ReadyForConnections(10 * time.Second) {
-		return fmt.Errorf("NATS server failed to start within 10 seconds")
+	for _, domain := range strings.Split(*leafDomains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			cfg.LeafDomains = append(cfg.LeafDomains, domain)
+		}
 	}
 
-	log.Printf("✅ NATS server started successfully")
-
-	// Test basic connectivity
-	if err := e.testConnectivity(); err != nil {
-		log.Printf("⚠️ Warning: connectivity test failed: %v", err)
-	} else {
-		log.Printf("✅ Connectivity test passed")
+	if *restore != "" {
+		dir, cleanup, err := fetchBackup(*restore)
+		if err != nil {
+			return fmt.Errorf("failed to fetch backup: %w", err)
+		}
+		defer cleanup()
+		cfg.RestoreDir = dir
 	}
 
-	// Create basic JetStream configuration for GitHub events
-	if err := e.setupGitHubStreams(); err != nil {
-		log.Printf("⚠️ Warning: failed to setup GitHub streams: %v", err)
-	} else {
-		log.Printf("✅ GitHub event streams configured")
+	if *clusterSize > 1 {
+		return runCluster(cfg, *clusterSize, *clusterPort)
 	}
 
-	return nil
-}
+	// Create embedded NATS
+	natsServer, err := embeddednats.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create NATS server: %w", err)
+	}
 
-// Stop stops the embedded NATS server
-func (e *EmbeddedNATS) Stop() {
-	log.Printf("🛑 Stopping embedded NATS server...")
+	// Setup graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	if e.server != nil {
-		e.server.Shutdown()
-		e.server.WaitForShutdown()
+	// Start server
+	if err := natsServer.Start(); err != nil {
+		return fmt.Errorf("failed to start NATS server: %w", err)
 	}
 
-	// Cleanup temporary directory
-	if e.tempDir != "" {
-		if err := os.RemoveAll(e.tempDir); err != nil {
-			log.Printf("Warning: failed to cleanup temp directory: %v", err)
-		} else {
-			log.Printf("✅ Temporary files cleaned up")
-		}
+	log.Printf("🎯 Bootstrap NATS ready for GitHub automation!")
+	log.Printf("   Connection URL: %s", natsServer.GetConnectionURL())
+	if monitorURL := natsServer.GetMonitorURL(); monitorURL != "" {
+		log.Printf("   Monitor URL: %s", monitorURL)
 	}
-
-	log.Printf("✅ NATS server stopped")
-}
-
-// testConnectivity tests basic NATS connectivity
-func (e *EmbeddedNATS) testConnectivity() error {
-	nc, err := nats.Connect(fmt.Sprintf("nats://%s:%d", e.opts.Host, e.opts.Port))
-	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+	if statusURL := natsServer.GetStatusURL(); statusURL != "" {
+		log.Printf("   Readiness: %s/readyz", statusURL)
 	}
-	defer nc.Close()
+	printClientSettings(cfg, natsServer)
+	log.Printf("   Press Ctrl+C to stop")
 
-	// Test basic pub/sub
-	if err := nc.Publish("test.bootstrap", []byte("Bootstrap test message")); err != nil {
-		return fmt.Errorf("failed to publish: %w", err)
-	}
+	// Wait for shutdown signal
+	<-sigChan
 
+	// Graceful shutdown
+	natsServer.Stop()
+	log.Printf("👋 Bootstrap complete!")
 	return nil
 }
 
-// setupGitHubStreams creates JetStream streams for GitHub events
-func (e *EmbeddedNATS) setupGitHubStreams() error {
-	nc, err := nats.Connect(fmt.Sprintf("nats://%s:%d", e.opts.Host, e.opts.Port))
+// runCluster starts a local JetStream cluster; SIGUSR1 restarts the next node
+// in turn to exercise failover
+func runCluster(cfg embeddednats.Options, size, clusterPort int) error {
+	cluster, err := embeddednats.NewCluster(cfg, size, clusterPort)
 	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return fmt.Errorf("failed to create NATS cluster: %w", err)
 	}
-	defer nc.Close()
 
-	js, err := nc.JetStream()
-	if err != nil {
-		return fmt.Errorf("failed to get JetStream context: %w", err)
-	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
 
-	// Create GitHub events stream
-	streamConfig := &nats.StreamConfig{
-		Name:        "GITHUB_EVENTS",
-		Description: "GitHub organization events for workflow automation",
-		Subjects:    []string{"github.>"},
-		Storage:     nats.FileStorage,
-		MaxAge:      24 * time.Hour,    // Keep events for 24 hours
-		MaxMsgs:     10000,             // Keep last 10k messages
-		MaxBytes:    100 * 1024 * 1024, // 100MB max
-		Replicas:    1,                 // Single replica for development
+	if err := cluster.Start(); err != nil {
+		return fmt.Errorf("failed to start NATS cluster: %w", err)
 	}
 
-	_, err = js.AddStream(streamConfig)
-	if err != nil && err != nats.ErrStreamNameAlreadyInUse {
-		return fmt.Errorf("failed to create GitHub events stream: %w", err)
+	log.Printf("🎯 Bootstrap NATS cluster (%d nodes) ready for GitHub automation!", size)
+	log.Printf("   export NATS_DEPLOYMENT_TYPE=self_hosted_cluster")
+	log.Printf("   export NATS_URLS=%s", cluster.GetConnectionURL())
+	for _, node := range cluster.Nodes() {
+		if monitorURL := node.GetMonitorURL(); monitorURL != "" {
+			log.Printf("   Monitor URL: %s", monitorURL)
+		}
 	}
-
-	// Create workflow coordination stream
-	workflowConfig := &nats.StreamConfig{
-		Name:        "WORKFLOW_COORDINATION",
-		Description: "Workflow coordination and locking",
-		Subjects:    []string{"workflow.>", "locks.>"},
-		Storage:     nats.FileStorage,
-		MaxAge:      1 * time.Hour,    // Keep locks for 1 hour max
-		MaxMsgs:     1000,             // Keep last 1k messages
-		MaxBytes:    10 * 1024 * 1024, // 10MB max
-		Replicas:    1,
+	if statusURL := cluster.Nodes()[0].GetStatusURL(); statusURL != "" {
+		log.Printf("   Readiness: %s/readyz", statusURL)
 	}
+	printClientSettings(cfg, cluster.Nodes()[0])
+	log.Printf("   Send SIGUSR1 (kill -USR1 %d) to restart the next node", os.Getpid())
+	log.Printf("   Press Ctrl+C to stop")
+
+	next := 0
+	for sig := range sigChan {
+		if sig != syscall.SIGUSR1 {
+			break
+		}
 
-	_, err = js.AddStream(workflowConfig)
-	if err != nil && err != nats.ErrStreamNameAlreadyInUse {
-		return fmt.Errorf("failed to create workflow coordination stream: %w", err)
+		log.Printf("🔁 Restarting node %d...", next+1)
+		if err := cluster.RestartNode(next); err != nil {
+			log.Printf("⚠️ Failed to restart node %d: %v", next+1, err)
+		}
+		next = (next + 1) % size
 	}
 
+	cluster.Stop()
+	log.Printf("👋 Bootstrap complete!")
 	return nil
 }
 
-// GetConnectionURL returns the NATS connection URL
-func (e *EmbeddedNATS) GetConnectionURL() string {
-	return fmt.Sprintf("nats://%s:%d", e.opts.Host, e.opts.Port)
-}
-
-// GetMonitorURL returns the HTTP monitor URL
-func (e *EmbeddedNATS) GetMonitorURL() string {
-	return fmt.Sprintf("http://%s:%d", e.opts.HTTPHost, e.opts.HTTPPort)
-}
-
-func main() {
-	log.Printf("🤖 NATS Bootstrap Server v%s", version)
-
-	// Create embedded NATS
-	natsServer, err := NewEmbeddedNATS()
-	if err != nil {
-		log.Fatalf("Failed to create NATS server: %v", err)
+// printClientSettings prints the environment a client needs for generated auth, leafnode and TLS
+func printClientSettings(cfg embeddednats.Options, natsServer *embeddednats.Server) {
+	if cfg.Auth {
+		log.Printf("🔐 Credentials:")
+		log.Printf("   GitHub automation: export NATS_CREDS_FILE=%s", natsServer.CredsFile(embeddednats.AccountGitHub))
+		log.Printf("   Playwright logging: export NATS_CREDS=%s", natsServer.CredsFile(embeddednats.AccountPlaywright))
+		log.Printf("   System: %s", natsServer.CredsFile(embeddednats.AccountSystem))
+		if cfg.StoreDir == "" {
+			log.Printf("   ⚠️ Keys live in a temp dir; use -store-dir to keep them across restarts")
+		}
 	}
 
-	// Setup graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// Start server
-	if err := natsServer.Start(); err != nil {
-		log.Fatalf("Failed to start NATS server: %v", err)
+	if cfg.LeafURL != "" {
+		upstream := "local events are not sent to the hub; see -events-upstream"
+		if cfg.EventsUpstream {
+			upstream = "local events reach it through the hub, which must source them with -leaf-domains " + natsServer.Domain()
+		}
+		log.Printf("🍃 Leafnode of %s (GITHUB_EVENTS %ss the hub's stream; %s):", cfg.LeafURL, cfg.EventsSync, upstream)
+		log.Printf("   export NATS_JETSTREAM_DOMAIN=%s", natsServer.Domain())
 	}
 
-	log.Printf("🎯 Bootstrap NATS ready for GitHub automation!")
-	log.Printf("   Connection URL: %s", natsServer.GetConnectionURL())
-	log.Printf("   Monitor URL: %s", natsServer.GetMonitorURL())
-	log.Printf("   Press Ctrl+C to stop")
-
-	// Wait for shutdown signal
-	<-sigChan
-
-	// Graceful shutdown
-	natsServer.Stop()
-	log.Printf("👋 Bootstrap complete!")
+	if files := natsServer.TLSFiles(); files != nil {
+		log.Printf("🔒 TLS (point the controller at it with):")
+		log.Printf("   export NATS_TLS_ENABLED=true")
+		log.Printf("   export NATS_TLS_CA_FILE=%s", files.CAFile)
+		if cfg.MTLS {
+			log.Printf("   export NATS_TLS_CERT_FILE=%s", files.ClientCertFile)
+			log.Printf("   export NATS_TLS_KEY_FILE=%s", files.ClientKeyFile)
+		}
+	}
 }
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Object is an object in S3-compatible storage (AWS S3, Cloudflare R2, MinIO)
+// addressed as s3://bucket/key. It speaks just enough of the S3 API, signed
+// with SigV4, to upload and download one backup archive.
+type s3Object struct {
+	Endpoint     *url.URL
+	Region       string
+	Bucket       string
+	Key          string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	client       *http.Client
+}
+
+// isS3URL reports whether a backup location is an s3:// URL
+func isS3URL(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// parseS3URL resolves s3://bucket/key against the environment:
+// AWS_ENDPOINT_URL (e.g. http://localhost:9000 for MinIO), AWS_REGION,
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. Without AWS credentials it falls
+// back to Cloudflare R2 via CLOUDFLARE_ACCOUNT_ID, CLOUDFLARE_R2_ACCESS_KEY and
+// CLOUDFLARE_R2_SECRET_KEY; the key pair always comes from one provider.
+func parseS3URL(location string) (*s3Object, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 location %q (want s3://bucket/key)", location)
+	}
+
+	if key := strings.TrimPrefix(u.Path, "/"); key == "" || strings.HasSuffix(key, "/") {
+		return nil, fmt.Errorf("invalid S3 location %q: no object key (want s3://bucket/key)", location)
+	}
+
+	obj := &s3Object{
+		Bucket: u.Host,
+		Key:    strings.TrimPrefix(u.Path, "/"),
+		Region: firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		client: &http.Client{Timeout: 10 * time.Minute},
+	}
+
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_SECRET_ACCESS_KEY") != "" {
+		obj.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		obj.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		obj.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	} else {
+		obj.AccessKey = os.Getenv("CLOUDFLARE_R2_ACCESS_KEY")
+		obj.SecretKey = os.Getenv("CLOUDFLARE_R2_SECRET_KEY")
+	}
+
+	endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		if account := os.Getenv("CLOUDFLARE_ACCOUNT_ID"); account != "" {
+			endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", account)
+			if obj.Region == "" {
+				obj.Region = "auto"
+			}
+		}
+	}
+	if obj.Region == "" {
+		obj.Region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", obj.Region)
+	}
+
+	obj.Endpoint, err = url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: %w", endpoint, err)
+	}
+	if obj.AccessKey == "" || obj.SecretKey == "" {
+		return nil, fmt.Errorf("S3 credentials not set (both AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or both CLOUDFLARE_R2_ACCESS_KEY and CLOUDFLARE_R2_SECRET_KEY)")
+	}
+
+	return obj, nil
+}
+
+// String returns the s3:// URL of the object
+func (o *s3Object) String() string {
+	return fmt.Sprintf("s3://%s/%s", o.Bucket, o.Key)
+}
+
+// Put uploads the file at path as the object
+func (o *s3Object) Put(path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	req, err := o.request(http.MethodPut, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", o, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to upload %s: %s", o, s3Error(resp))
+	}
+	return nil
+}
+
+// Get downloads the object into the file at path
+func (o *s3Object) Get(path string) error {
+	req, err := o.request(http.MethodGet, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", o, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", o, s3Error(resp))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to download %s: %w", o, err)
+	}
+	return f.Close()
+}
+
+// request builds a SigV4-signed, path-style request for the object
+func (o *s3Object) request(method string, body []byte) (*http.Request, error) {
+	u := *o.Endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + o.Bucket + "/" + o.Key
+	u.RawPath = s3EscapePath(u.Path) // send the path exactly as it is signed
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build S3 request: %w", err)
+	}
+
+	payloadHash := sha256Hex(body)
+	now := time.Now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if o.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", o.SessionToken)
+	}
+
+	o.sign(req, payloadHash, now)
+	return req, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header
+func (o *s3Object) sign(req *http.Request, payloadHash string, now time.Time) {
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, o.Region)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+o.SecretKey), date)
+	key = hmacSHA256(key, o.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		o.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3EscapePath URI-encodes each segment of path as SigV4 requires: everything
+// but letters, digits and -._~ is percent-encoded, unlike url.PathEscape
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		var b strings.Builder
+		for j := 0; j < len(segment); j++ {
+			c := segment[j]
+			if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3Error summarises an S3 error response
+func s3Error(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if len(body) == 0 {
+		return resp.Status
+	}
+	return fmt.Sprintf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// firstEnv returns the first non-empty environment variable of names
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestParseS3URL(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	tests := []struct {
+		location string
+		bucket   string
+		key      string
+		wantErr  bool
+	}{
+		{"s3://backups/nats/latest.tar.gz", "backups", "nats/latest.tar.gz", false},
+		{"s3://backups/latest.tar.gz", "backups", "latest.tar.gz", false},
+		{"s3://backups", "", "", true},
+		{"s3://backups/", "", "", true},
+		{"s3://backups/nats/", "", "", true},
+		{"s3:///latest.tar.gz", "", "", true},
+	}
+	for _, tt := range tests {
+		obj, err := parseS3URL(tt.location)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseS3URL(%q) accepted %s", tt.location, obj)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseS3URL(%q): %v", tt.location, err)
+			continue
+		}
+		if obj.Bucket != tt.bucket || obj.Key != tt.key {
+			t.Errorf("parseS3URL(%q) = bucket %q key %q, want %q %q", tt.location, obj.Bucket, obj.Key, tt.bucket, tt.key)
+		}
+	}
+}
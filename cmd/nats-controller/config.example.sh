@@ -11,6 +11,70 @@
 export GITHUB_ORG="joeblew999"
 export NATS_DEPLOYMENT_TYPE="self_hosted"  # or synadia_cloud, hybrid
 
+# =============================================================================
+# Multiple GitHub Organizations
+# =============================================================================
+
+# One controller process can serve several orgs. Each org gets its own
+# JetStream consumer (workflow-controller-<org>), handlers, GitHub token and
+# API budget. GITHUB_ORGS takes precedence over GITHUB_ORG.
+#
+# Upgrading from the single-org controller: stop the old controller and start
+# this one; nothing else is needed. On first start each org's consumer resumes
+# after the ack floor of the old workflow-controller consumer when that
+# consumer filtered github.<org>.>, and the old consumer is deleted. Without
+# it, a new consumer starts with new events, so retained events are never
+# dispatched again (use `nats-controller replay` to process them on purpose).
+export GITHUB_ORGS="joeblew999,acme-corp"
+
+# Shared GitHub settings, used by any org without an override
+export GITHUB_TOKEN="ghp_shared_token"
+export GITHUB_RATE_LIMIT="5000"              # API calls per hour (token bucket refill)
+export GITHUB_RATE_BURST="10"                # calls allowed back-to-back
+export GITHUB_STATS_INTERVAL="60"            # seconds between stats snapshots, 0 disables
+export GITHUB_API_URL="https://api.github.com"
+
+# Per-org overrides use the upper-cased org name with non-alphanumerics as _
+export GITHUB_TOKEN_ACME_CORP="ghp_acme_token"
+export GITHUB_RATE_LIMIT_ACME_CORP="1000"
+
+# =============================================================================
+# Local Development with Auth and TLS (nats-bootstrap -auth -mtls)
+# =============================================================================
+
+# `go run ./cmd/nats-bootstrap -auth -store-dir .nats-bootstrap` generates an
+# operator with separate GITHUB and PLAYWRIGHT accounts, like Synadia Cloud.
+# Keys are kept in the store dir, so the creds stay valid across restarts.
+export NATS_URLS="nats://127.0.0.1:4222"
+export NATS_CREDS_FILE=".nats-bootstrap/auth/github.creds"
+
+# Playwright logging (logging/) uses its own account:
+# export NATS_CREDS=".nats-bootstrap/auth/playwright.creds"
+
+# Adding -tls (or -mtls to require client certs) generates a dev CA and
+# certificates in .nats-bootstrap/tls; the CA is trusted via NATS_TLS_CA_FILE
+export NATS_TLS_ENABLED="true"
+export NATS_TLS_CA_FILE=".nats-bootstrap/tls/ca.pem"
+export NATS_TLS_CERT_FILE=".nats-bootstrap/tls/client.pem"   # -mtls only
+export NATS_TLS_KEY_FILE=".nats-bootstrap/tls/client-key.pem" # -mtls only
+
+# =============================================================================
+# Laptop Leafnode (nats-bootstrap -leaf)
+# =============================================================================
+
+# `go run ./cmd/nats-bootstrap -leaf tls://connect.ngs.global:7422 -leaf-creds
+# synadia.creds -hub-domain ngs` runs a local leafnode with its own JetStream
+# domain ("leaf"). GITHUB_EVENTS sources the hub's stream, keeps local events
+# while offline and catches up on reconnect (-events-sync mirror for read-only).
+# Local events stay local unless the leafnode runs with -events-upstream and a
+# unique -domain (e.g. leaf-alice), and the hub sources them: a nats-bootstrap
+# hub with -leaf-domains leaf-alice,leaf-bob, or a GITHUB_EVENTS_UPSTREAM
+# source with that domain on the hub's stream. The hub stream followed with
+# -hub-stream must have limits retention; work-queue streams are rejected.
+# The controller connects to the leafnode as usual:
+export NATS_URLS="nats://127.0.0.1:4222"
+export NATS_JETSTREAM_DOMAIN="leaf"
+
 # =============================================================================
 # Synadia Cloud Configuration
 # =============================================================================
@@ -146,10 +210,73 @@ export GITHUB_ORG="joeblew999-dev"
 # Monitoring and Observability
 # =============================================================================
 
-# The controller provides monitoring endpoints when running:
-# - Health check: Will be available when monitoring server is implemented
-# - Metrics: Will provide Prometheus-compatible metrics
-# - Status: Will show current connection status and event processing
+# Enable the monitoring server:
+export CONTROLLER_HTTP_ADDR=":9090"
+
+# Endpoints:
+# - /healthz: 200 while connected to NATS
+# - /metrics: Prometheus metrics labelled by org, e.g.
+#     controller_events_processed_total{org="joeblew999"} 42
+#     controller_github_budget_remaining{org="acme-corp"} 998
+
+# =============================================================================
+# Remote Control
+# =============================================================================
+
+# Each org's controller answers JSON request/reply commands on
+# github.<org>.control.<command>, mirroring playwright.control.* in
+# logging/nats-controller.js:
+# nats req github.joeblew999.control.status ''
+# nats req github.joeblew999.control.pause ''
+# nats req github.joeblew999.control.resume ''
+# nats req github.joeblew999.control.reload-config ''
+# nats req github.joeblew999.control.replay '{"since":"1h"}'
+# nats req github.joeblew999.control.replay '{"since":"2024-12-19T10:00:00Z"}'
+#
+# Replies look like: {"success":true,"command":"status","org":"joeblew999","result":{...}}
+
+# =============================================================================
+# GitHub Rate Limiting
+# =============================================================================
+
+# All handlers for an org share one token bucket. Every GitHub response's
+# X-RateLimit-Limit/Remaining/Reset headers update the known quota:
+# - below 20% remaining only PRIORITY_HIGH and PRIORITY_URGENT events run
+# - below 5% remaining only PRIORITY_URGENT events run
+# - at 0 remaining dispatching pauses until the reset time
+# Deferred events are NAKed with a delay until the quota resets. An event's
+# priority comes from "priority" or "data.priority" (default PRIORITY_NORMAL).
+#
+# GitHubAPIStats snapshots are published on system.<org>.github_api:
+# nats sub 'system.*.github_api'
+
+# =============================================================================
+# Replaying Past Events
+# =============================================================================
+
+# The replay subcommand reads GITHUB_EVENTS with an ephemeral ordered consumer
+# (durable consumers are untouched) using the same NATS_* settings as above.
+# Print events as NDJSON (default) or a JSON array:
+# ./nats-controller replay -since 2h
+# ./nats-controller replay -since 2024-12-19T10:00:00Z -org joeblew999 -type template_changed
+# ./nats-controller replay -seq 1500 -repo .github -format json -limit 50
+#
+# Re-feed them through the controller handlers and print the dry-run plan:
+# ./nats-controller replay -since 30m -org joeblew999 -feed
+
+# =============================================================================
+# Dry Run
+# =============================================================================
+
+# Handlers run fully, but NATS publishes, GitHub API calls and Terraform runs
+# are recorded as planned actions. New traffic is read through an ephemeral
+# ordered consumer, so the production durable consumer keeps its messages.
+# The plan is written as JSON on shutdown:
+# ./nats-controller -dry-run                          # plan to stdout
+# ./nats-controller -dry-run -plan-file plan.json
+#
+# Inspect the plan while running:
+# nats req github.joeblew999.control.plan ''
 
 # =============================================================================
 # Security Best Practices
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/embeddednats/embeddednatstest"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// newTestController returns a controller for org on a fresh embedded server
+func newTestController(t *testing.T, org *OrgConfig) *Controller {
+	t.Helper()
+	s := embeddednatstest.StartTestServer(t)
+	nc := embeddednatstest.Connect(t, s)
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewController(org, nc, js, &NATSConfig{}, NewMetrics())
+}
+
+// publishEvents publishes n workflow_status events for org, returning their sequences
+func publishEvents(t *testing.T, js jetstream.JetStream, org string, n int) []uint64 {
+	t.Helper()
+	var seqs []uint64
+	for i := 0; i < n; i++ {
+		data := fmt.Sprintf(`{"org":%q,"event_type":"workflow_status","data":{"workflow":"w%d"}}`, org, i)
+		ack, err := js.Publish(context.Background(), fmt.Sprintf("github.%s.workflow_status", org), []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, ack.Sequence)
+	}
+	return seqs
+}
+
+// fetchSequences fetches what the consumer delivers within a second
+func fetchSequences(t *testing.T, consumer jetstream.Consumer) []uint64 {
+	t.Helper()
+	msgs, err := consumer.Fetch(10, jetstream.FetchMaxWait(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seqs []uint64
+	for msg := range msgs.Messages() {
+		meta, err := msg.Metadata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, meta.Sequence.Stream)
+	}
+	return seqs
+}
+
+func TestEnsureConsumerStartsWithNewEvents(t *testing.T) {
+	c := newTestController(t, &OrgConfig{Name: "acme"})
+	ctx := context.Background()
+	publishEvents(t, c.js, "acme", 3)
+
+	consumer, err := c.ensureConsumer(ctx, c.consumerName(), "github.acme.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seqs := fetchSequences(t, consumer); len(seqs) != 0 {
+		t.Fatalf("new consumer delivered retained events %v", seqs)
+	}
+
+	fresh := publishEvents(t, c.js, "acme", 1)
+	if seqs := fetchSequences(t, consumer); len(seqs) != 1 || seqs[0] != fresh[0] {
+		t.Fatalf("delivered %v, want %v", seqs, fresh)
+	}
+}
+
+func TestEnsureConsumerMigratesLegacyConsumer(t *testing.T) {
+	c := newTestController(t, &OrgConfig{Name: "acme"})
+	ctx := context.Background()
+	seqs := publishEvents(t, c.js, "acme", 3)
+
+	legacy, err := c.js.CreateOrUpdateConsumer(ctx, eventsStream, jetstream.ConsumerConfig{
+		Durable:       legacyConsumerName,
+		FilterSubject: "github.acme.>",
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := legacy.Fetch(2, jetstream.FetchMaxWait(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for msg := range msgs.Messages() {
+		if err := msg.DoubleAck(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	consumer, err := c.ensureConsumer(ctx, c.consumerName(), "github.acme.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fetchSequences(t, consumer); len(got) != 1 || got[0] != seqs[2] {
+		t.Errorf("migrated consumer delivered %v, want [%d]", got, seqs[2])
+	}
+	if _, err := c.js.Consumer(ctx, eventsStream, legacyConsumerName); !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		t.Errorf("legacy consumer still present: %v", err)
+	}
+
+	// Restarting keeps the consumer's position
+	if _, err := c.ensureConsumer(ctx, c.consumerName(), "github.acme.*"); err != nil {
+		t.Fatalf("ensureConsumer on restart: %v", err)
+	}
+}
+
+func TestEnsureConsumerKeepsOtherOrgsLegacyConsumer(t *testing.T) {
+	c := newTestController(t, &OrgConfig{Name: "acme"})
+	ctx := context.Background()
+
+	if _, err := c.js.CreateOrUpdateConsumer(ctx, eventsStream, jetstream.ConsumerConfig{
+		Durable:       legacyConsumerName,
+		FilterSubject: "github.other.>",
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ensureConsumer(ctx, c.consumerName(), "github.acme.*"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.js.Consumer(ctx, eventsStream, legacyConsumerName); err != nil {
+		t.Errorf("legacy consumer of another org was removed: %v", err)
+	}
+}
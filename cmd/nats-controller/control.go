@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/dryrun"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// maxReplayDuration bounds how long a single replay request may run
+const maxReplayDuration = 10 * time.Minute
+
+// ControlReply is the JSON reply sent for every control request
+type ControlReply struct {
+	Success bool        `json:"success"`
+	Command string      `json:"command"`
+	Org     string      `json:"org"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// ControllerStatus describes a running controller
+type ControllerStatus struct {
+	Org             string           `json:"org"`
+	Version         string           `json:"version"`
+	Paused          bool             `json:"paused"`
+	DryRun          bool             `json:"dry_run"`
+	StartedAt       time.Time        `json:"started_at"`
+	NATSConnected   bool             `json:"nats_connected"`
+	NATSURL         string           `json:"nats_url"`
+	Consumer        string           `json:"consumer"`
+	PendingMessages uint64           `json:"pending_messages"`
+	AckPending      int              `json:"ack_pending"`
+	GitHubEnabled   bool             `json:"github_enabled"`
+	GitHubRemaining int              `json:"github_budget_remaining"`
+	GitHubAPI       GitHubAPIStats   `json:"github_api"`
+	Counters        map[string]int64 `json:"counters"`
+}
+
+// ReplayRequest is the payload of a replay control request
+type ReplayRequest struct {
+	// Since is an RFC 3339 timestamp or a duration such as "1h" before now
+	Since string `json:"since"`
+	// Live performs the replayed side effects; otherwise they are only recorded
+	Live bool `json:"live"`
+}
+
+// ReplayResult is the reply to a replay control request
+type ReplayResult struct {
+	Replayed int             `json:"replayed"`
+	DryRun   bool            `json:"dry_run"`
+	Plan     []dryrun.Action `json:"plan,omitempty"`
+}
+
+// controlSubject returns the control subject for a command of this controller's organization
+func (c *Controller) controlSubject(command string) string {
+	return fmt.Sprintf("github.%s.control.%s", c.org, command)
+}
+
+// handleControl answers control requests on github.<org>.control.<command>
+func (c *Controller) handleControl(msg *nats.Msg) {
+	command := msg.Subject[strings.LastIndex(msg.Subject, ".")+1:]
+	log.Printf("🎛️  [%s] Control command: %s", c.org, command)
+
+	var (
+		result interface{}
+		err    error
+	)
+
+	switch command {
+	case "pause":
+		c.paused.Store(true)
+		result = c.status()
+	case "resume":
+		c.paused.Store(false)
+		result = c.status()
+	case "status":
+		result = c.status()
+	case "reload-config":
+		result, err = c.reloadConfig()
+	case "plan":
+		if !c.plan.Enabled() {
+			err = fmt.Errorf("controller is not running in dry-run mode")
+			break
+		}
+		result = c.plan.Actions()
+	case "replay":
+		// Replays can take a while, so answer from a goroutine to keep the
+		// subscription free for other commands
+		go func() {
+			// A malformed stored event must not take the controller down
+			defer func() {
+				if r := recover(); r != nil {
+					c.respondControl(msg, command, nil, fmt.Errorf("replay aborted: %v", r))
+				}
+			}()
+			result, err := c.handleReplay(msg.Data)
+			c.respondControl(msg, command, result, err)
+		}()
+		return
+	default:
+		err = fmt.Errorf("unknown control command %q", command)
+	}
+
+	c.respondControl(msg, command, result, err)
+}
+
+// respondControl sends the JSON reply for a control request
+func (c *Controller) respondControl(msg *nats.Msg, command string, result interface{}, err error) {
+	reply := ControlReply{
+		Success: err == nil,
+		Command: command,
+		Org:     c.org,
+		Result:  result,
+	}
+	if err != nil {
+		reply.Result = nil
+		reply.Error = err.Error()
+		log.Printf("⚠️ [%s] Control command %s failed: %v", c.org, command, err)
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		log.Printf("Failed to marshal control reply: %v", err)
+		return
+	}
+
+	if msg.Reply == "" {
+		return
+	}
+	if err := msg.Respond(data); err != nil {
+		log.Printf("Failed to send control reply: %v", err)
+	}
+}
+
+// status collects the controller's current state
+func (c *Controller) status() *ControllerStatus {
+	status := &ControllerStatus{
+		Org:             c.org,
+		Version:         version,
+		Paused:          c.paused.Load(),
+		DryRun:          c.plan.Enabled(),
+		StartedAt:       c.startedAt,
+		NATSConnected:   c.nc.IsConnected(),
+		NATSURL:         c.nc.ConnectedUrl(),
+		Consumer:        c.consumerName(),
+		GitHubEnabled:   c.github.Enabled(),
+		GitHubRemaining: c.github.Remaining(),
+		GitHubAPI:       c.github.limiter.Stats(c.org),
+		Counters: map[string]int64{
+			"events_processed": c.metrics.Get(metricEventsProcessed, c.org),
+			"events_unhandled": c.metrics.Get(metricEventsUnhandled, c.org),
+			"events_deferred":  c.metrics.Get(metricEventsDeferred, c.org),
+			"fetch_errors":     c.metrics.Get(metricFetchErrors, c.org),
+			"github_calls":     c.metrics.Get(metricGitHubCalls, c.org),
+			"github_errors":    c.metrics.Get(metricGitHubErrors, c.org),
+		},
+	}
+
+	if c.consumer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if info, err := c.consumer.Info(ctx); err == nil {
+			status.PendingMessages = info.NumPending
+			status.AckPending = info.NumAckPending
+		}
+	}
+
+	return status
+}
+
+// reloadConfig re-reads this organization's settings from the environment
+func (c *Controller) reloadConfig() (*OrgConfig, error) {
+	org, err := loadOrgConfig(c.org)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.orgCfg = org
+	c.mu.Unlock()
+
+	c.github.SetConfig(org)
+
+	log.Printf("🔧 [%s] Configuration reloaded (GitHub rate %d/hour, burst %d)", c.org, org.RateLimitPerHour, org.RateBurst)
+	return org, nil
+}
+
+// orgConfig returns the organization settings currently in use
+func (c *Controller) orgConfig() *OrgConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.orgCfg
+}
+
+// handleReplay parses a replay request and replays the matching events. Replays
+// are dry runs unless the request asks for live side effects.
+func (c *Controller) handleReplay(data []byte) (*ReplayResult, error) {
+	var req ReplayRequest
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, fmt.Errorf("invalid replay request: %w", err)
+		}
+	}
+
+	since, err := parseSince(req.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxReplayDuration)
+	defer cancel()
+
+	// A dry-run controller only ever records, so its replays go into its plan
+	if !req.Live || c.plan.Enabled() {
+		plan := c.plan
+		target := c
+		if !plan.Enabled() {
+			plan = dryrun.NewPlan()
+			target = NewController(c.orgConfig(), c.nc, c.js, c.config, NewMetrics())
+			target.SetPlan(plan)
+		}
+
+		count, err := target.replay(ctx, since)
+		result := &ReplayResult{Replayed: count, DryRun: true}
+		if plan != c.plan {
+			result.Plan = plan.Actions()
+		}
+		return result, err
+	}
+
+	if c.paused.Load() {
+		return nil, fmt.Errorf("controller is paused; resume it before a live replay")
+	}
+	count, err := c.replay(ctx, since)
+	return &ReplayResult{Replayed: count}, err
+}
+
+// replay feeds events stored since the given time back through the handlers.
+// Live replays stop when the controller is paused and wait for GitHub quota
+// like consumed events do.
+func (c *Controller) replay(ctx context.Context, since time.Time) (int, error) {
+	log.Printf("⏪ [%s] Replaying events since %s", c.org, since.Format(time.RFC3339))
+
+	filter := ReplayFilter{
+		Subject:   fmt.Sprintf("github.%s.*", c.org),
+		StartTime: since,
+	}
+
+	count, err := replayEvents(ctx, c.js, filter, func(_ ReplayedEvent, msg jetstream.Msg) error {
+		if !c.plan.Enabled() {
+			if err := c.admitReplay(ctx, msg.Subject(), msg.Data()); err != nil {
+				return err
+			}
+		}
+		c.dispatch(msg.Subject(), msg.Data())
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	log.Printf("✅ [%s] Replayed %d events", c.org, count)
+	return count, nil
+}
+
+// admitReplay blocks until a replayed event may be dispatched, failing when the
+// controller is paused or ctx ends first. Only events that call GitHub wait
+// for quota.
+func (c *Controller) admitReplay(ctx context.Context, subject string, data []byte) error {
+	priority := eventPriority(data)
+	for {
+		if c.paused.Load() {
+			return fmt.Errorf("controller was paused during replay")
+		}
+		if !callsGitHub(subject) {
+			return nil
+		}
+		ok, retryAfter := c.github.limiter.Admit(priority)
+		if ok {
+			return nil
+		}
+
+		retryAfter = min(max(retryAfter, time.Second), time.Minute)
+		log.Printf("⏳ [%s] Holding replay (%s) for %s: GitHub quota low", c.org, priority, retryAfter.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+}
+
+// parseSince parses an RFC 3339 timestamp or a duration relative to now
+func parseSince(since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, fmt.Errorf("since is required (RFC 3339 time or duration like 1h)")
+	}
+
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q: want RFC 3339 time or duration", since)
+	}
+
+	return time.Now().Add(-d), nil
+}
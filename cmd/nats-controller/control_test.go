@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestReplaySkipsMalformedEvents(t *testing.T) {
+	c := newTestController(t, &OrgConfig{Name: "acme"})
+	sub, err := c.nc.Subscribe(c.controlSubject("*"), c.handleControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, data := range []string{
+		`{"org":"acme","event_type":"workflow_status","data":{"status":"completed"}}`,
+		`{"org":"acme","event_type":"workflow_status","data":{"workflow":"ci","status":7}}`,
+		`not json`,
+	} {
+		if _, err := c.js.Publish(context.Background(), "github.acme.workflow_status", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, live := range []bool{false, true} {
+		req, _ := json.Marshal(ReplayRequest{Since: "1h", Live: live})
+		msg, err := c.nc.Request(c.controlSubject("replay"), req, 10*time.Second)
+		if err != nil {
+			t.Fatalf("replay (live %v): %v", live, err)
+		}
+
+		var reply struct {
+			ControlReply
+			Result ReplayResult `json:"result"`
+		}
+		if err := json.Unmarshal(msg.Data, &reply); err != nil {
+			t.Fatal(err)
+		}
+		// The undecodable event is skipped before it reaches a handler
+		if !reply.Success || reply.Result.Replayed != 2 || reply.Result.DryRun == live {
+			t.Errorf("replay (live %v) = %s", live, msg.Data)
+		}
+	}
+}
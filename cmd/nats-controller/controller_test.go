@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestControllerDispatchesRegenerationForTemplateChanges(t *testing.T) {
+	dispatched := make(chan string, 4)
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "bad credentials", http.StatusUnauthorized)
+			return
+		}
+		dispatched <- r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer github.Close()
+
+	c := newTestController(t, &OrgConfig{
+		Name:             "acme",
+		GitHubToken:      "test-token",
+		GitHubAPIURL:     github.URL,
+		RateLimitPerHour: defaultRateLimitPerHour,
+		RateBurst:        10,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The consumer starts with new events, so publish once it exists
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := c.js.Consumer(ctx, eventsStream, c.consumerName()); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("controller did not create its consumer")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// template_changed -> regeneration_requested -> workflow dispatch
+	event := `{"org":"acme","repo":"widgets","event_type":"template_changed","data":{"files":["workflows/ci.yml"]}}`
+	if _, err := c.js.Publish(ctx, "github.acme.template_changed", []byte(event)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-dispatched:
+		if want := "POST /repos/acme/widgets/actions/workflows/" + regenerateWorkflow + "/dispatches"; got != want {
+			t.Errorf("GitHub request = %s, want %s", got, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no workflow dispatched")
+	}
+
+	// Both events are acked once handled
+	consumer, err := c.js.Consumer(ctx, eventsStream, c.consumerName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		info, err := consumer.Info(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.NumAckPending == 0 && info.NumPending == 0 && info.AckFloor.Stream == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("events not acked: %+v", info)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/dryrun"
+)
+
+// GitHubClient makes authenticated GitHub API calls for a single organization,
+// paced by that organization's rate limiter
+type GitHubClient struct {
+	org     *OrgConfig
+	http    *http.Client
+	metrics *Metrics
+	plan    *dryrun.Plan
+	limiter *RateLimiter
+
+	mu sync.Mutex
+}
+
+// NewGitHubClient creates a GitHub API client for an organization
+func NewGitHubClient(org *OrgConfig, metrics *Metrics) *GitHubClient {
+	client := &GitHubClient{
+		org:     org,
+		http:    &http.Client{Timeout: 30 * time.Second},
+		metrics: metrics,
+		limiter: NewRateLimiter(org.RateLimitPerHour, org.RateBurst),
+	}
+	metrics.Set(metricGitHubBudget, org.Name, int64(client.limiter.Remaining()))
+	return client
+}
+
+// Enabled reports whether the client has credentials to call GitHub
+func (g *GitHubClient) Enabled() bool {
+	return g.config().GitHubToken != ""
+}
+
+// config returns the organization settings currently in use
+func (g *GitHubClient) config() *OrgConfig {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.org
+}
+
+// SetConfig swaps in new organization settings and rate limits
+func (g *GitHubClient) SetConfig(org *OrgConfig) {
+	g.mu.Lock()
+	g.org = org
+	g.mu.Unlock()
+
+	g.limiter.SetRate(org.RateLimitPerHour, org.RateBurst)
+}
+
+// Remaining returns the GitHub API calls left in the current quota window
+func (g *GitHubClient) Remaining() int {
+	return g.limiter.Remaining()
+}
+
+// do sends a JSON request to the GitHub API and decodes a JSON response into out
+func (g *GitHubClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	org := g.config()
+	if g.plan.Enabled() {
+		g.plan.Record(dryrun.Action{
+			Kind:    dryrun.KindGitHubAPI,
+			Org:     org.Name,
+			Target:  path,
+			Summary: fmt.Sprintf("%s %s", method, path),
+			Payload: body,
+		})
+		log.Printf("   📝 [dry-run] GitHub %s %s recorded", method, path)
+		return nil
+	}
+
+	if org.GitHubToken == "" {
+		return fmt.Errorf("no GitHub token configured for %s", org.Name)
+	}
+
+	if err := g.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("waiting for GitHub rate limit: %w", err)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := strings.TrimSuffix(org.GitHubAPIURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+org.GitHubToken)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	g.metrics.Inc(metricGitHubCalls, org.Name)
+
+	resp, err := g.http.Do(req)
+	if err != nil {
+		g.limiter.Observe(nil, false)
+		g.metrics.Inc(metricGitHubErrors, org.Name)
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	g.limiter.Observe(resp.Header, resp.StatusCode < 300)
+	g.metrics.Set(metricGitHubBudget, org.Name, int64(g.limiter.Remaining()))
+
+	if resp.StatusCode >= 300 {
+		g.metrics.Inc(metricGitHubErrors, org.Name)
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DispatchWorkflow triggers a workflow_dispatch event for a workflow file in a repository
+func (g *GitHubClient) DispatchWorkflow(ctx context.Context, repo, workflow, ref string, inputs map[string]string) error {
+	path := fmt.Sprintf("/repos/%s/%s/actions/workflows/%s/dispatches", g.config().Name, repo, workflow)
+
+	body := map[string]interface{}{"ref": ref}
+	if len(inputs) > 0 {
+		body["inputs"] = inputs
+	}
+
+	return g.do(ctx, http.MethodPost, path, body, nil)
+}
@@ -3,20 +3,45 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/joeblew999/.github/pkg/dryrun"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
 const version = "1.0.0"
 
+// eventsStream is the JetStream stream holding GitHub events
+const eventsStream = "GITHUB_EVENTS"
+
+// regenerateWorkflow is the workflow file dispatched for regeneration requests
+const regenerateWorkflow = "regenerate-github-files.yml"
+
+// githubEventTypes are the event types whose handlers call the GitHub API;
+// only these are held back when GitHub quota runs low
+var githubEventTypes = map[string]bool{
+	"regeneration_requested": true,
+}
+
+// callsGitHub reports whether handling an event on subject calls the GitHub API
+func callsGitHub(subject string) bool {
+	return githubEventTypes[subjectEventType(subject)]
+}
+
 // NATSConfig holds NATS connection configuration
 type NATSConfig struct {
 	URLs            []string `json:"urls"`
@@ -46,20 +71,115 @@ type GitHubEvent struct {
 	Data      map[string]interface{} `json:"data"`
 }
 
-// Controller handles GitHub workflow orchestration via NATS
+// Controller handles GitHub workflow orchestration via NATS for one organization
 type Controller struct {
 	nc       *nats.Conn
 	js       jetstream.JetStream
 	org      string
+	orgCfg   *OrgConfig
 	config   *NATSConfig
+	github   *GitHubClient
+	metrics  *Metrics
 	subjects map[string]nats.MsgHandler
+
+	// plan records publishes and GitHub calls instead of performing them (dry-run)
+	plan *dryrun.Plan
+
+	// Runtime state exposed over the control plane
+	consumer  jetstream.Consumer
+	paused    atomic.Bool
+	startedAt time.Time
+	mu        sync.Mutex
+}
+
+// Supervisor runs one controller per organization over a shared NATS connection
+type Supervisor struct {
+	nc          *nats.Conn
+	js          jetstream.JetStream
+	config      *NATSConfig
+	metrics     *Metrics
+	controllers []*Controller
+	plan        *dryrun.Plan
+}
+
+// NewSupervisor connects to NATS once and creates a controller for each organization
+func NewSupervisor(orgs []*OrgConfig, config *NATSConfig) (*Supervisor, error) {
+	name := fmt.Sprintf("github-controller-%s", strings.Join(orgNames(orgs), ","))
+
+	nc, err := connectNATS(name, config)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := newJetStream(nc, config)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	s := &Supervisor{
+		nc:      nc,
+		js:      js,
+		config:  config,
+		metrics: NewMetrics(),
+	}
+
+	for _, org := range orgs {
+		s.controllers = append(s.controllers, NewController(org, nc, js, config, s.metrics))
+	}
+
+	return s, nil
+}
+
+// NewController creates a workflow controller for one organization on an existing connection
+func NewController(org *OrgConfig, nc *nats.Conn, js jetstream.JetStream, config *NATSConfig, metrics *Metrics) *Controller {
+	controller := &Controller{
+		nc:       nc,
+		js:       js,
+		org:      org.Name,
+		orgCfg:   org,
+		config:   config,
+		github:   NewGitHubClient(org, metrics),
+		metrics:  metrics,
+		subjects: make(map[string]nats.MsgHandler),
+	}
+
+	// Setup event handlers
+	controller.setupHandlers()
+
+	return controller
 }
 
-// NewController creates a new workflow controller with flexible NATS configuration
-func NewController(org string, config *NATSConfig) (*Controller, error) {
+// SetPlan switches the controller to dry-run mode, recording side effects in plan
+func (c *Controller) SetPlan(plan *dryrun.Plan) {
+	c.plan = plan
+	c.github.plan = plan
+}
+
+// newJetStream creates a JetStream context, scoped to a domain when talking to
+// a leafnode or hub with its own JetStream domain
+func newJetStream(nc *nats.Conn, config *NATSConfig) (jetstream.JetStream, error) {
+	var (
+		js  jetstream.JetStream
+		err error
+	)
+	if config.JetStreamDomain != "" {
+		log.Printf("JetStream domain: %s", config.JetStreamDomain)
+		js, err = jetstream.NewWithDomain(nc, config.JetStreamDomain)
+	} else {
+		js, err = jetstream.New(nc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	return js, nil
+}
+
+// connectNATS builds the connection options for the configured deployment type and connects
+func connectNATS(name string, config *NATSConfig) (*nats.Conn, error) {
 	// Build NATS connection options
 	opts := []nats.Option{
-		nats.Name(fmt.Sprintf("github-controller-%s", org)),
+		nats.Name(name),
 		nats.MaxReconnects(config.MaxReconnect),
 		nats.ReconnectWait(time.Duration(config.ReconnectWait) * time.Second),
 		nats.Timeout(time.Duration(config.Timeout) * time.Second),
@@ -100,6 +220,18 @@ func NewController(org string, config *NATSConfig) (*Controller, error) {
 			tlsConfig.Certificates = []tls.Certificate{cert}
 		}
 
+		if config.TLSCAFile != "" {
+			caPEM, err := os.ReadFile(config.TLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read TLS CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return nil, fmt.Errorf("no certificates found in TLS CA file %s", config.TLSCAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
 		opts = append(opts, nats.Secure(tlsConfig))
 	}
 
@@ -109,30 +241,7 @@ func NewController(org string, config *NATSConfig) (*Controller, error) {
 		return nil, fmt.Errorf("failed to connect to NATS (%s): %w", config.DeploymentType, err)
 	}
 
-	// Create JetStream context
-	jsOpts := []jetstream.JetStreamOpt{}
-	if config.JetStreamDomain != "" {
-		// Note: Domain support may require newer NATS version
-		log.Printf("JetStream domain requested: %s", config.JetStreamDomain)
-	}
-
-	js, err := jetstream.New(nc, jsOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
-	}
-
-	controller := &Controller{
-		nc:       nc,
-		js:       js,
-		org:      org,
-		config:   config,
-		subjects: make(map[string]nats.MsgHandler),
-	}
-
-	// Setup event handlers
-	controller.setupHandlers()
-
-	return controller, nil
+	return nc, nil
 }
 
 // setupHandlers configures event handlers for different GitHub events
@@ -199,8 +308,16 @@ func (c *Controller) handleWorkflowStatus(msg *nats.Msg) {
 		return
 	}
 
-	workflow := event.Data["workflow"].(string)
-	status := event.Data["status"].(string)
+	workflow, ok := event.Data["workflow"].(string)
+	if !ok {
+		log.Printf("No workflow in workflow status event, skipping")
+		return
+	}
+	status, ok := event.Data["status"].(string)
+	if !ok {
+		log.Printf("No status in workflow status event for %s, skipping", workflow)
+		return
+	}
 
 	log.Printf("📊 Workflow status: %s - %s", workflow, status)
 
@@ -227,12 +344,30 @@ func (c *Controller) handleRegenerationRequest(msg *nats.Msg) {
 
 	log.Printf("🤖 Regeneration requested for %s", event.Repo)
 
-	// In a real implementation, this could:
+	// In a real implementation, this could also:
 	// 1. Queue the regeneration request
-	// 2. Check rate limits
-	// 3. Coordinate with other pending requests
-	// 4. Trigger GitHub Actions via API
-	// 5. Monitor progress
+	// 2. Coordinate with other pending requests
+	// 3. Monitor progress
+
+	if !c.github.Enabled() && !c.plan.Enabled() {
+		log.Printf("   No GitHub token for %s, skipping workflow dispatch", c.org)
+		return
+	}
+
+	repo := event.Repo
+	if repo == "" {
+		repo = ".github"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := c.github.DispatchWorkflow(ctx, repo, regenerateWorkflow, "main", nil); err != nil {
+		log.Printf("Failed to dispatch %s for %s/%s: %v", regenerateWorkflow, c.org, repo, err)
+		return
+	}
+
+	log.Printf("   Dispatched %s for %s/%s", regenerateWorkflow, c.org, repo)
 }
 
 // publishEvent publishes an event to NATS
@@ -243,82 +378,240 @@ func (c *Controller) publishEvent(event GitHubEvent) error {
 	}
 
 	subject := fmt.Sprintf("github.%s.%s", event.Org, event.EventType)
+	if c.plan.Enabled() {
+		c.plan.Record(dryrun.Action{
+			Kind:    dryrun.KindNATSPublish,
+			Org:     c.org,
+			Target:  subject,
+			Summary: fmt.Sprintf("publish %s event for %s", event.EventType, event.Repo),
+			Payload: dryrun.Payload(data),
+		})
+		log.Printf("   📝 [dry-run] publish to %s recorded", subject)
+		return nil
+	}
 	return c.nc.Publish(subject, data)
 }
 
-// Start begins the controller event loop
+// consumerName returns the durable consumer name for this controller's organization
+func (c *Controller) consumerName() string {
+	return fmt.Sprintf("workflow-controller-%s", c.org)
+}
+
+// legacyConsumerName is the durable consumer used before controllers served
+// several organizations; it filtered github.<org>.>
+const legacyConsumerName = "workflow-controller"
+
+// ensureConsumer creates or updates the organization's durable consumer. A
+// new consumer resumes after the legacy consumer's ack floor, deleting it, or
+// else starts with new events, so an upgrade never dispatches the retained
+// events again.
+func (c *Controller) ensureConsumer(ctx context.Context, name, filter string) (jetstream.Consumer, error) {
+	cfg := jetstream.ConsumerConfig{
+		Name:          name,
+		Durable:       name,
+		FilterSubject: filter,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	}
+
+	existing, err := c.js.Consumer(ctx, eventsStream, name)
+	switch {
+	case err == nil:
+		// The deliver policy of an existing consumer cannot change
+		info := existing.CachedInfo()
+		cfg.DeliverPolicy = info.Config.DeliverPolicy
+		cfg.OptStartSeq = info.Config.OptStartSeq
+		cfg.OptStartTime = info.Config.OptStartTime
+		return c.js.CreateOrUpdateConsumer(ctx, eventsStream, cfg)
+	case !errors.Is(err, jetstream.ErrConsumerNotFound):
+		return nil, fmt.Errorf("failed to look up consumer %s: %w", name, err)
+	}
+
+	cfg.DeliverPolicy = jetstream.DeliverNewPolicy
+	migrate := false
+	legacy, err := c.js.Consumer(ctx, eventsStream, legacyConsumerName)
+	switch {
+	case err == nil && legacy.CachedInfo().Config.FilterSubject == fmt.Sprintf("github.%s.>", c.org):
+		info := legacy.CachedInfo()
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = info.AckFloor.Stream + 1
+		migrate = true
+	case err == nil:
+		log.Printf("[%s] Leaving consumer %s, it filters %s", c.org, legacyConsumerName, legacy.CachedInfo().Config.FilterSubject)
+	case !errors.Is(err, jetstream.ErrConsumerNotFound):
+		return nil, fmt.Errorf("failed to look up consumer %s: %w", legacyConsumerName, err)
+	}
+
+	consumer, err := c.js.CreateOrUpdateConsumer(ctx, eventsStream, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if migrate {
+		log.Printf("🔀 [%s] Migrated consumer %s to %s from stream sequence %d", c.org, legacyConsumerName, name, cfg.OptStartSeq)
+		if err := c.js.DeleteConsumer(ctx, eventsStream, legacyConsumerName); err != nil {
+			log.Printf("[%s] Failed to delete consumer %s: %v", c.org, legacyConsumerName, err)
+		}
+	}
+	return consumer, nil
+}
+
+// Start begins the controller event loop and blocks until the context is cancelled
 func (c *Controller) Start(ctx context.Context) error {
-	log.Printf("🚀 Starting GitHub workflow controller v%s", version)
-	log.Printf("   Organization: %s", c.org)
-	log.Printf("   NATS connection: %s", c.nc.ConnectedUrl())
+	log.Printf("🚀 Starting GitHub workflow controller for %s", c.org)
 
 	// Setup JetStream consumer for persistent event processing
-	streamName := "GITHUB_EVENTS"
-	consumerName := "workflow-controller"
-
-	// Create or get consumer
-	consumer, err := c.js.CreateOrUpdateConsumer(ctx, streamName, jetstream.ConsumerConfig{
-		Name:          consumerName,
-		Durable:       consumerName,
-		FilterSubject: fmt.Sprintf("github.%s.>", c.org),
-		AckPolicy:     jetstream.AckExplicitPolicy,
-	})
+	consumerName := c.consumerName()
+
+	// Events are github.<org>.<event_type>; the single-token wildcard keeps
+	// control requests out of the consumer.
+	filter := fmt.Sprintf("github.%s.*", c.org)
+
+	var (
+		consumer jetstream.Consumer
+		err      error
+	)
+	if c.plan.Enabled() {
+		// Dry runs watch new traffic through an ephemeral ordered consumer so
+		// the production durable consumer never loses messages to them
+		consumerName = "ordered (dry-run)"
+		consumer, err = c.js.OrderedConsumer(ctx, eventsStream, jetstream.OrderedConsumerConfig{
+			FilterSubjects: []string{filter},
+			DeliverPolicy:  jetstream.DeliverNewPolicy,
+		})
+	} else {
+		consumer, err = c.ensureConsumer(ctx, consumerName, filter)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create consumer: %w", err)
+		return fmt.Errorf("failed to create consumer for %s: %w", c.org, err)
 	}
 
-	// Start consuming messages
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				// Fetch messages
-				msgs, err := consumer.Fetch(10, jetstream.FetchMaxWait(time.Second))
-				if err != nil {
-					log.Printf("Failed to fetch messages: %v", err)
-					time.Sleep(time.Second)
-					continue
-				}
-
-				// Process each message
-				for msg := range msgs.Messages() {
-					c.processMessage(msg)
-				}
-			}
-		}
-	}()
+	c.consumer = consumer
 
-	log.Printf("✅ Controller started and listening for events")
+	// Listen for remote control requests
+	controlSub, err := c.nc.Subscribe(c.controlSubject("*"), c.handleControl)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to control subjects for %s: %w", c.org, err)
+	}
+	defer controlSub.Unsubscribe()
 
-	// Wait for shutdown signal
-	<-ctx.Done()
-	log.Printf("🛑 Shutting down controller...")
+	c.startedAt = time.Now().UTC()
 
-	c.nc.Close()
-	return nil
+	go c.publishAPIStats(ctx)
+
+	log.Printf("✅ Controller for %s listening on consumer %s", c.org, consumerName)
+	log.Printf("   Control subjects: %s", c.controlSubject("*"))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+			if c.paused.Load() {
+				time.Sleep(time.Second)
+				continue
+			}
+
+			// Fetch messages
+			msgs, err := consumer.Fetch(10, jetstream.FetchMaxWait(time.Second))
+			if err != nil {
+				log.Printf("[%s] Failed to fetch messages: %v", c.org, err)
+				c.metrics.Inc(metricFetchErrors, c.org)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			// Process each message
+			for msg := range msgs.Messages() {
+				c.processMessage(msg)
+			}
+		}
+	}
 }
 
 // processMessage processes individual NATS messages
 func (c *Controller) processMessage(msg jetstream.Msg) {
-	// Extract subject and route to appropriate handler
-	subject := msg.Subject()
+	if c.plan.Enabled() {
+		c.dispatch(msg.Subject(), msg.Data())
+		return // ordered consumers do not take acks
+	}
+
+	// Defer lower-priority GitHub work first when GitHub quota runs low;
+	// events handled without calling GitHub always run
+	if callsGitHub(msg.Subject()) {
+		priority := eventPriority(msg.Data())
+		if ok, retryAfter := c.github.limiter.Admit(priority); !ok {
+			retryAfter = min(max(retryAfter, time.Second), time.Hour)
+			log.Printf("⏳ [%s] Deferring %s (%s) for %s: GitHub quota low", c.org, msg.Subject(), priority, retryAfter.Round(time.Second))
+			c.metrics.Inc(metricEventsDeferred, c.org)
+			msg.NakWithDelay(retryAfter)
+			return
+		}
+	}
+
+	c.dispatch(msg.Subject(), msg.Data())
+	msg.Ack() // Unhandled subjects are acknowledged too, to prevent redelivery
+}
+
+// eventPriority reads the priority of an event, defaulting to normal
+func eventPriority(data []byte) Priority {
+	var event struct {
+		Priority string                 `json:"priority"`
+		Data     map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return PriorityNormal
+	}
+
+	if event.Priority == "" {
+		event.Priority, _ = event.Data["priority"].(string)
+	}
+	return parsePriority(event.Priority)
+}
+
+// publishAPIStats periodically publishes GitHubAPIStats snapshots on system.<org>.github_api
+func (c *Controller) publishAPIStats(ctx context.Context) {
+	interval := c.orgConfig().StatsInterval
+	if interval == 0 || c.plan.Enabled() {
+		return
+	}
 
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	subject := fmt.Sprintf("system.%s.github_api", c.org)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data, err := json.Marshal(c.github.limiter.Stats(c.org))
+			if err != nil {
+				log.Printf("Failed to marshal GitHub API stats: %v", err)
+				continue
+			}
+			if err := c.nc.Publish(subject, data); err != nil {
+				log.Printf("Failed to publish GitHub API stats: %v", err)
+			}
+		}
+	}
+}
+
+// dispatch routes a message to the handler registered for its subject
+func (c *Controller) dispatch(subject string, data []byte) bool {
 	for pattern, handler := range c.subjects {
 		// Simple pattern matching - in production, use proper subject matching
 		if matchSubject(pattern, subject) {
 			handler(&nats.Msg{
 				Subject: subject,
-				Data:    msg.Data(),
+				Data:    data,
 			})
-			msg.Ack()
-			return
+			c.metrics.Inc(metricEventsProcessed, c.org)
+			return true
 		}
 	}
 
 	log.Printf("No handler for subject: %s", subject)
-	msg.Ack() // Acknowledge to prevent redelivery
+	c.metrics.Inc(metricEventsUnhandled, c.org)
+	return false
 }
 
 // matchSubject performs simple subject pattern matching
@@ -327,14 +620,90 @@ func matchSubject(pattern, subject string) bool {
 	return subject == pattern
 }
 
-// MonitoringServer provides HTTP endpoints for health and metrics
-func (c *Controller) StartMonitoringServer() {
-	// In a real implementation, this would provide:
-	// - Health check endpoints
-	// - Prometheus metrics
-	// - Controller status
-	// - Event processing statistics
-	log.Printf("📊 Monitoring server would start here (HTTP endpoints)")
+// Start runs every organization's controller and blocks until the context is cancelled
+func (s *Supervisor) Start(ctx context.Context) error {
+	log.Printf("🚀 Starting GitHub workflow controller v%s", version)
+	log.Printf("   Organizations: %v", orgNames(s.orgs()))
+	log.Printf("   NATS connection: %s", s.nc.ConnectedUrl())
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(s.controllers))
+	for _, controller := range s.controllers {
+		go func(c *Controller) {
+			errs <- c.Start(ctx)
+		}(controller)
+	}
+
+	log.Printf("✅ Controller started and listening for events")
+
+	// A failing organization stops the whole process so it can be restarted cleanly
+	var firstErr error
+	for range s.controllers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	log.Printf("🛑 Shutting down controller...")
+
+	s.nc.Close()
+	return firstErr
+}
+
+// SetPlan switches every controller to dry-run mode, sharing one plan
+func (s *Supervisor) SetPlan(plan *dryrun.Plan) {
+	s.plan = plan
+	for _, c := range s.controllers {
+		c.SetPlan(plan)
+	}
+}
+
+// orgs returns the configuration of every supervised organization
+func (s *Supervisor) orgs() []*OrgConfig {
+	orgs := make([]*OrgConfig, len(s.controllers))
+	for i, c := range s.controllers {
+		orgs[i] = c.orgConfig()
+	}
+	return orgs
+}
+
+// StartMonitoringServer serves health and Prometheus metrics over HTTP when addr is set
+func (s *Supervisor) StartMonitoringServer(addr string) {
+	if addr == "" {
+		log.Printf("📊 Monitoring server disabled (set CONTROLLER_HTTP_ADDR to enable)")
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.nc.IsConnected() {
+			http.Error(w, "nats disconnected", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		// The budget gauge is otherwise only updated by GitHub calls, so
+		// refresh it to pick up rate limit windows that reset since
+		for _, c := range s.controllers {
+			s.metrics.Set(metricGitHubBudget, c.org, int64(c.github.Remaining()))
+		}
+		if err := s.metrics.WritePrometheus(w); err != nil {
+			log.Printf("Failed to write metrics: %v", err)
+		}
+	})
+
+	go func() {
+		log.Printf("📊 Monitoring server listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Monitoring server stopped: %v", err)
+		}
+	}()
 }
 
 // configureSynadiaAuth configures authentication for Synadia Cloud
@@ -479,7 +848,38 @@ func getDefaultNATSURLs(deploymentType string) []string {
 	}
 }
 
+// writePlan writes a dry-run plan as JSON to a file, or stdout for "-"
+func writePlan(plan *dryrun.Plan, path string) error {
+	if path == "-" {
+		return plan.WriteJSON(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := plan.WriteJSON(f); err != nil {
+		return err
+	}
+
+	log.Printf("📝 Dry-run plan written to %s", path)
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(os.Args[2:]); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+
+	dryRun := flag.Bool("dry-run", false, "Record side effects as a JSON plan instead of executing them")
+	planFile := flag.String("plan-file", "-", "Where to write the dry-run plan on shutdown (- for stdout)")
+	flag.Parse()
+
 	log.Printf("🤖 NATS GitHub Controller v%s", version)
 
 	// Load NATS configuration from environment and context
@@ -498,33 +898,46 @@ func main() {
 		config.URLs = getDefaultNATSURLs(config.DeploymentType)
 	}
 
-	org := os.Getenv("GITHUB_ORG")
-	if org == "" {
-		org = "joeblew999"
+	orgs, err := loadOrgConfigs()
+	if err != nil {
+		log.Fatalf("Failed to load GitHub organizations: %v", err)
 	}
 
 	log.Printf("🔧 Configuration:")
-	log.Printf("   GitHub Org: %s", org)
+	log.Printf("   GitHub Orgs: %v", orgNames(orgs))
 	log.Printf("   Deployment Type: %s", config.DeploymentType)
 	log.Printf("   NATS URLs: %v", config.URLs)
 	log.Printf("   JetStream Domain: %s", config.JetStreamDomain)
 	log.Printf("   TLS Enabled: %v", config.TLSEnabled)
+	log.Printf("   Dry Run: %v", *dryRun)
 
-	// Create controller
-	controller, err := NewController(org, config)
+	// Create one controller per organization on a shared connection
+	supervisor, err := NewSupervisor(orgs, config)
 	if err != nil {
 		log.Fatalf("Failed to create controller: %v", err)
 	}
 
+	if *dryRun {
+		supervisor.SetPlan(dryrun.NewPlan())
+	}
+
 	// Setup graceful shutdown
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	// Start monitoring server
-	controller.StartMonitoringServer()
+	supervisor.StartMonitoringServer(os.Getenv("CONTROLLER_HTTP_ADDR"))
+
+	// Start the controllers
+	err = supervisor.Start(ctx)
+
+	if *dryRun {
+		if planErr := writePlan(supervisor.plan, *planFile); planErr != nil {
+			log.Printf("Failed to write dry-run plan: %v", planErr)
+		}
+	}
 
-	// Start the controller
-	if err := controller.Start(ctx); err != nil {
+	if err != nil {
 		log.Fatalf("Controller error: %v", err)
 	}
 
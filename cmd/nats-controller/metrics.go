@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Metric names exported by the controller
+const (
+	metricEventsProcessed = "controller_events_processed_total"
+	metricEventsUnhandled = "controller_events_unhandled_total"
+	metricEventsDeferred  = "controller_events_deferred_total"
+	metricFetchErrors     = "controller_fetch_errors_total"
+	metricGitHubCalls     = "controller_github_api_calls_total"
+	metricGitHubErrors    = "controller_github_api_errors_total"
+	metricGitHubBudget    = "controller_github_budget_remaining"
+)
+
+// metricHelp describes each metric for the Prometheus exposition format
+var metricHelp = map[string]string{
+	metricEventsProcessed: "Events dispatched to a handler",
+	metricEventsUnhandled: "Events with no matching handler",
+	metricEventsDeferred:  "Events deferred because GitHub quota was low",
+	metricFetchErrors:     "Failed JetStream fetch attempts",
+	metricGitHubCalls:     "GitHub API calls made",
+	metricGitHubErrors:    "GitHub API calls that failed",
+	metricGitHubBudget:    "GitHub API calls left in the current rate limit window",
+}
+
+// metricKey identifies a single org-labelled series
+type metricKey struct {
+	name string
+	org  string
+}
+
+// Metrics holds controller counters and gauges labelled by organization
+type Metrics struct {
+	mu     sync.Mutex
+	values map[metricKey]int64
+	gauges map[string]bool
+}
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		values: make(map[metricKey]int64),
+		gauges: map[string]bool{metricGitHubBudget: true},
+	}
+}
+
+// Inc increments a counter for an organization
+func (m *Metrics) Inc(name, org string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[metricKey{name, org}]++
+}
+
+// Set sets a gauge for an organization
+func (m *Metrics) Set(name, org string, value int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[metricKey{name, org}] = value
+}
+
+// Get returns the current value of a series
+func (m *Metrics) Get(name, org string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[metricKey{name, org}]
+}
+
+// WritePrometheus writes all series in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	keys := make([]metricKey, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	values := make(map[metricKey]int64, len(m.values))
+	for key, value := range m.values {
+		values[key] = value
+	}
+	m.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].org < keys[j].org
+	})
+
+	var last string
+	for _, key := range keys {
+		if key.name != last {
+			metricType := "counter"
+			if m.gauges[key.name] {
+				metricType = "gauge"
+			}
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", key.name, metricHelp[key.name], key.name, metricType); err != nil {
+				return err
+			}
+			last = key.name
+		}
+		if _, err := fmt.Fprintf(w, "%s{org=%q} %d\n", key.name, key.org, values[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Default outbound GitHub pacing: GitHub's authenticated REST API quota, with
+// the burst from the rate_limit section of bee.yaml
+const (
+	defaultRateLimitPerHour = 5000
+	defaultRateBurst        = 10
+)
+
+// OrgConfig holds the per-organization settings for a controller
+type OrgConfig struct {
+	Name             string `json:"name"`
+	GitHubToken      string `json:"-"`
+	GitHubAPIURL     string `json:"github_api_url"`
+	RateLimitPerHour int    `json:"rate_limit_per_hour"`
+	RateBurst        int    `json:"rate_burst"`
+	StatsInterval    int    `json:"stats_interval_seconds"`
+}
+
+// loadOrgConfigs loads the list of organizations served by this process.
+//
+// GITHUB_ORGS (or the legacy GITHUB_ORG) accepts a comma-separated list.
+// Credentials and budgets can be set per org with an upper-cased suffix,
+// e.g. GITHUB_TOKEN_ACME_CORP, falling back to the unsuffixed variable.
+func loadOrgConfigs() ([]*OrgConfig, error) {
+	names := os.Getenv("GITHUB_ORGS")
+	if names == "" {
+		names = os.Getenv("GITHUB_ORG")
+	}
+	if names == "" {
+		names = "joeblew999"
+	}
+
+	var orgs []*OrgConfig
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		org, err := loadOrgConfig(name)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("no GitHub organizations configured")
+	}
+
+	return orgs, nil
+}
+
+// loadOrgConfig loads the settings for a single organization
+func loadOrgConfig(name string) (*OrgConfig, error) {
+	org := &OrgConfig{
+		Name:             name,
+		GitHubToken:      orgEnv(name, "GITHUB_TOKEN"),
+		GitHubAPIURL:     orgEnv(name, "GITHUB_API_URL"),
+		RateLimitPerHour: defaultRateLimitPerHour,
+		RateBurst:        defaultRateBurst,
+		StatsInterval:    60,
+	}
+
+	if org.GitHubAPIURL == "" {
+		org.GitHubAPIURL = "https://api.github.com"
+	}
+
+	if limit := orgEnv(name, "GITHUB_RATE_LIMIT"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid GITHUB_RATE_LIMIT for %s: %q", name, limit)
+		}
+		org.RateLimitPerHour = n
+	}
+
+	if burst := orgEnv(name, "GITHUB_RATE_BURST"); burst != "" {
+		n, err := strconv.Atoi(burst)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid GITHUB_RATE_BURST for %s: %q", name, burst)
+		}
+		org.RateBurst = n
+	}
+
+	if interval := orgEnv(name, "GITHUB_STATS_INTERVAL"); interval != "" {
+		n, err := strconv.Atoi(interval)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid GITHUB_STATS_INTERVAL for %s: %q", name, interval)
+		}
+		org.StatsInterval = n
+	}
+
+	return org, nil
+}
+
+// orgEnv looks up an org-specific environment variable, falling back to the shared one
+func orgEnv(org, key string) string {
+	if value := os.Getenv(key + "_" + orgEnvSuffix(org)); value != "" {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+// orgEnvSuffix turns an org name into an environment variable suffix
+func orgEnvSuffix(org string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, org)
+}
+
+// orgNames returns the names of the configured organizations
+func orgNames(orgs []*OrgConfig) []string {
+	names := make([]string, len(orgs))
+	for i, org := range orgs {
+		names[i] = org.Name
+	}
+	return names
+}
@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+func TestOrgEnvSuffix(t *testing.T) {
+	tests := map[string]string{
+		"joeblew999": "JOEBLEW999",
+		"acme-corp":  "ACME_CORP",
+		"Acme.Corp":  "ACME_CORP",
+		"a_b":        "A_B",
+	}
+	for org, want := range tests {
+		if got := orgEnvSuffix(org); got != want {
+			t.Errorf("orgEnvSuffix(%q) = %q, want %q", org, got, want)
+		}
+	}
+}
+
+func TestLoadOrgConfig(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "shared-token")
+	t.Setenv("GITHUB_TOKEN_ACME_CORP", "acme-token")
+	t.Setenv("GITHUB_RATE_LIMIT", "")
+	t.Setenv("GITHUB_RATE_LIMIT_ACME_CORP", "1000")
+	t.Setenv("GITHUB_RATE_BURST", "")
+	t.Setenv("GITHUB_API_URL", "")
+	t.Setenv("GITHUB_STATS_INTERVAL", "0")
+
+	tests := []struct {
+		org       string
+		wantToken string
+		wantLimit int
+	}{
+		{"acme-corp", "acme-token", 1000},
+		{"joeblew999", "shared-token", defaultRateLimitPerHour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.org, func(t *testing.T) {
+			org, err := loadOrgConfig(tt.org)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if org.GitHubToken != tt.wantToken {
+				t.Errorf("token = %q, want %q", org.GitHubToken, tt.wantToken)
+			}
+			if org.RateLimitPerHour != tt.wantLimit {
+				t.Errorf("rate limit = %d, want %d", org.RateLimitPerHour, tt.wantLimit)
+			}
+			if org.RateBurst != defaultRateBurst || org.StatsInterval != 0 || org.GitHubAPIURL != "https://api.github.com" {
+				t.Errorf("defaults = burst %d, stats %d, url %q", org.RateBurst, org.StatsInterval, org.GitHubAPIURL)
+			}
+		})
+	}
+}
+
+func TestLoadOrgConfigRejectsBadNumbers(t *testing.T) {
+	for _, key := range []string{"GITHUB_RATE_LIMIT_ACME", "GITHUB_RATE_BURST_ACME", "GITHUB_STATS_INTERVAL_ACME"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, "-1")
+			if _, err := loadOrgConfig("acme"); err == nil {
+				t.Errorf("%s=-1 accepted", key)
+			}
+		})
+	}
+}
+
+func TestLoadOrgConfigs(t *testing.T) {
+	tests := []struct {
+		orgs, org string
+		want      []string
+	}{
+		{"acme, acme-corp,,acme", "ignored", []string{"acme", "acme-corp"}},
+		{"", "legacy", []string{"legacy"}},
+		{"", "", []string{"joeblew999"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("GITHUB_ORGS", tt.orgs)
+		t.Setenv("GITHUB_ORG", tt.org)
+
+		orgs, err := loadOrgConfigs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := orgNames(orgs)
+		if len(got) != len(tt.want) {
+			t.Errorf("GITHUB_ORGS=%q GITHUB_ORG=%q: orgs %v, want %v", tt.orgs, tt.org, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("GITHUB_ORGS=%q GITHUB_ORG=%q: orgs %v, want %v", tt.orgs, tt.org, got, tt.want)
+				break
+			}
+		}
+	}
+
+	t.Setenv("GITHUB_ORGS", " , ")
+	if _, err := loadOrgConfigs(); err == nil {
+		t.Error("an empty org list was accepted")
+	}
+}
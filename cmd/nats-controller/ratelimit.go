@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Priority mirrors the Priority enum in schemas/github_events.proto
+type Priority int
+
+const (
+	PriorityUnknown Priority = iota
+	PriorityLow
+	PriorityNormal
+	PriorityHigh
+	PriorityUrgent
+)
+
+// Quota watermarks, as a fraction of the GitHub rate limit, below which
+// lower-priority work is deferred
+const (
+	lowQuotaWatermark      = 0.20 // below this only high and urgent work runs
+	criticalQuotaWatermark = 0.05 // below this only urgent work runs
+)
+
+// parsePriority maps a proto enum name such as "PRIORITY_HIGH" to a Priority
+func parsePriority(name string) Priority {
+	switch name {
+	case "PRIORITY_LOW":
+		return PriorityLow
+	case "PRIORITY_NORMAL":
+		return PriorityNormal
+	case "PRIORITY_HIGH":
+		return PriorityHigh
+	case "PRIORITY_URGENT":
+		return PriorityUrgent
+	default:
+		return PriorityNormal
+	}
+}
+
+// String returns the proto enum name of the priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "PRIORITY_LOW"
+	case PriorityNormal:
+		return "PRIORITY_NORMAL"
+	case PriorityHigh:
+		return "PRIORITY_HIGH"
+	case PriorityUrgent:
+		return "PRIORITY_URGENT"
+	default:
+		return "PRIORITY_UNKNOWN"
+	}
+}
+
+// GitHubAPIStats mirrors the GitHubAPIStats message in schemas/github_events.proto
+type GitHubAPIStats struct {
+	Org                string    `json:"org"`
+	Timestamp          time.Time `json:"timestamp"`
+	APICallsPerHour    int64     `json:"api_calls_per_hour"`
+	RateLimitRemaining int64     `json:"rate_limit_remaining"`
+	RateLimitReset     time.Time `json:"rate_limit_reset"`
+	APISuccessRate     float64   `json:"api_success_rate"`
+}
+
+// RateLimiter is a token bucket for outbound GitHub calls that also tracks the
+// quota GitHub reports in X-RateLimit-* response headers
+type RateLimiter struct {
+	mu sync.Mutex
+
+	// Token bucket refilled at the configured calls-per-hour rate
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	// Quota as last reported by GitHub
+	limit     int
+	remaining int
+	reset     time.Time
+	observed  bool
+
+	// Call history for GitHubAPIStats
+	calls     []time.Time
+	successes int64
+	failures  int64
+}
+
+// NewRateLimiter creates a limiter allowing perHour calls with the given burst
+func NewRateLimiter(perHour, burst int) *RateLimiter {
+	l := &RateLimiter{last: time.Now()}
+	l.SetRate(perHour, burst)
+	l.tokens = l.burst
+	return l
+}
+
+// SetRate changes the refill rate and burst size
+func (l *RateLimiter) SetRate(perHour, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	l.rate = float64(perHour) / 3600
+	l.burst = float64(max(burst, 1))
+	l.tokens = min(l.tokens, l.burst)
+	if !l.observed {
+		l.limit = perHour
+		l.remaining = perHour
+	}
+}
+
+// refill adds the tokens accrued since the last update; callers hold l.mu
+func (l *RateLimiter) refill(now time.Time) {
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// Wait blocks until a call may be made: a token is available and GitHub's
+// quota is not exhausted
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available, otherwise returns how long to wait
+func (l *RateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if wait := l.pausedFor(now); wait > 0 {
+		return wait
+	}
+
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// pausedFor returns how long calls should pause because GitHub's quota is
+// exhausted, or zero if calls may proceed; callers hold l.mu
+func (l *RateLimiter) pausedFor(now time.Time) time.Duration {
+	if !l.observed || l.remaining > 0 || !now.Before(l.reset) {
+		return 0
+	}
+	return l.reset.Sub(now)
+}
+
+// Admit decides whether work of the given priority may run now. When quota is
+// low, lower-priority work is deferred first; retryAfter says when to try again.
+func (l *RateLimiter) Admit(priority Priority) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if wait := l.pausedFor(now); wait > 0 {
+		return false, wait
+	}
+
+	if !l.observed || l.limit == 0 || !now.Before(l.reset) {
+		return true, 0
+	}
+
+	fraction := float64(l.remaining) / float64(l.limit)
+	switch {
+	case fraction < criticalQuotaWatermark && priority < PriorityUrgent,
+		fraction < lowQuotaWatermark && priority < PriorityHigh:
+		return false, l.reset.Sub(now)
+	}
+
+	return true, 0
+}
+
+// Observe records the outcome of a call and the quota headers GitHub returned
+func (l *RateLimiter) Observe(header http.Header, success bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.calls = append(l.calls, now)
+	l.trimCalls(now)
+	if success {
+		l.successes++
+	} else {
+		l.failures++
+	}
+
+	if header == nil {
+		return
+	}
+
+	limit, errLimit := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	remaining, errRemaining := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, errReset := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if errLimit != nil || errRemaining != nil || errReset != nil {
+		return
+	}
+
+	l.limit = limit
+	l.remaining = remaining
+	l.reset = time.Unix(reset, 0)
+	l.observed = true
+}
+
+// trimCalls drops call timestamps older than an hour; callers hold l.mu
+func (l *RateLimiter) trimCalls(now time.Time) {
+	cutoff := now.Add(-time.Hour)
+	i := 0
+	for i < len(l.calls) && l.calls[i].Before(cutoff) {
+		i++
+	}
+	l.calls = l.calls[i:]
+}
+
+// Remaining returns the calls GitHub reports as left, or the configured
+// hourly rate before any response has been seen
+func (l *RateLimiter) Remaining() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.remainingAt(time.Now())
+}
+
+// remainingAt implements Remaining: once the reported reset time has passed
+// the full limit is available again; callers hold l.mu
+func (l *RateLimiter) remainingAt(now time.Time) int {
+	if l.observed && !now.Before(l.reset) {
+		return l.limit
+	}
+	return l.remaining
+}
+
+// Stats returns a GitHubAPIStats snapshot for an organization
+func (l *RateLimiter) Stats(org string) GitHubAPIStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.trimCalls(now)
+
+	stats := GitHubAPIStats{
+		Org:                org,
+		Timestamp:          now.UTC(),
+		APICallsPerHour:    int64(len(l.calls)),
+		RateLimitRemaining: int64(l.remainingAt(now)),
+		APISuccessRate:     1,
+	}
+	if l.observed {
+		stats.RateLimitReset = l.reset.UTC()
+	}
+	if total := l.successes + l.failures; total > 0 {
+		stats.APISuccessRate = float64(l.successes) / float64(total)
+	}
+
+	return stats
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// quotaHeader returns the X-RateLimit-* headers GitHub sends
+func quotaHeader(limit, remaining int, reset time.Time) http.Header {
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return h
+}
+
+func TestRateLimiterAdmit(t *testing.T) {
+	soon := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		header    http.Header // nil: nothing observed yet
+		priority  Priority
+		wantOK    bool
+		wantRetry bool
+	}{
+		{"nothing observed", nil, PriorityLow, true, false},
+		{"plenty left", quotaHeader(5000, 2500, soon), PriorityLow, true, false},
+		{"low quota defers normal", quotaHeader(5000, 500, soon), PriorityNormal, false, true},
+		{"low quota admits high", quotaHeader(5000, 500, soon), PriorityHigh, true, false},
+		{"critical quota defers high", quotaHeader(5000, 100, soon), PriorityHigh, false, true},
+		{"critical quota admits urgent", quotaHeader(5000, 100, soon), PriorityUrgent, true, false},
+		{"exhausted defers urgent", quotaHeader(5000, 0, soon), PriorityUrgent, false, true},
+		{"reset passed", quotaHeader(5000, 0, past), PriorityLow, true, false},
+		{"zero limit and remaining", quotaHeader(0, 0, soon), PriorityLow, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(5000, 10)
+			if tt.header != nil {
+				l.Observe(tt.header, true)
+			}
+			ok, retry := l.Admit(tt.priority)
+			if ok != tt.wantOK || (retry > 0) != tt.wantRetry {
+				t.Errorf("Admit(%s) = %v, %s; want %v, retry %v", tt.priority, ok, retry, tt.wantOK, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestRateLimiterObserveHeaders(t *testing.T) {
+	reset := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name          string
+		header        http.Header
+		wantRemaining int
+		wantReset     time.Time
+	}{
+		{"quota headers", quotaHeader(5000, 42, reset), 42, reset},
+		{"no headers", http.Header{}, 1000, time.Time{}},
+		{"transport error", nil, 1000, time.Time{}},
+		{"malformed remaining", func() http.Header {
+			h := quotaHeader(5000, 42, reset)
+			h.Set("X-RateLimit-Remaining", "lots")
+			return h
+		}(), 1000, time.Time{}},
+		{"missing reset", func() http.Header {
+			h := quotaHeader(5000, 42, reset)
+			h.Del("X-RateLimit-Reset")
+			return h
+		}(), 1000, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(1000, 10)
+			l.Observe(tt.header, tt.header != nil)
+
+			stats := l.Stats("acme")
+			if got := l.Remaining(); got != tt.wantRemaining {
+				t.Errorf("Remaining() = %d, want %d", got, tt.wantRemaining)
+			}
+			if stats.RateLimitRemaining != int64(tt.wantRemaining) {
+				t.Errorf("Stats().RateLimitRemaining = %d, want %d", stats.RateLimitRemaining, tt.wantRemaining)
+			}
+			if !stats.RateLimitReset.Equal(tt.wantReset) {
+				t.Errorf("Stats().RateLimitReset = %s, want %s", stats.RateLimitReset, tt.wantReset)
+			}
+			if stats.APICallsPerHour != 1 {
+				t.Errorf("Stats().APICallsPerHour = %d, want 1", stats.APICallsPerHour)
+			}
+		})
+	}
+}
+
+func TestRateLimiterResetWindow(t *testing.T) {
+	l := NewRateLimiter(5000, 10)
+	l.Observe(quotaHeader(5000, 0, time.Now().Add(-time.Second)), true)
+
+	if got := l.Remaining(); got != 5000 {
+		t.Errorf("Remaining() after reset = %d, want 5000", got)
+	}
+	if got := l.Stats("acme").RateLimitRemaining; got != 5000 {
+		t.Errorf("Stats().RateLimitRemaining after reset = %d, want 5000", got)
+	}
+	if ok, _ := l.Admit(PriorityLow); !ok {
+		t.Error("Admit deferred work after the quota reset")
+	}
+}
+
+func TestRateLimiterWaitBurst(t *testing.T) {
+	l := NewRateLimiter(3600, 2) // one token a second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatalf("call %d within the burst waited: %v", i+1, err)
+		}
+	}
+	if err := l.Wait(ctx); err == nil {
+		t.Error("call beyond the burst did not wait")
+	}
+}
+
+func TestCallsGitHub(t *testing.T) {
+	tests := map[string]bool{
+		"github.acme.regeneration_requested": true,
+		"github.acme.template_changed":       false,
+		"github.acme.workflow_status":        false,
+		"github.acme-corp.control.pause":     false,
+	}
+	for subject, want := range tests {
+		if got := callsGitHub(subject); got != want {
+			t.Errorf("callsGitHub(%q) = %v, want %v", subject, got, want)
+		}
+	}
+}
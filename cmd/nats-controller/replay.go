@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/dryrun"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ReplayFilter selects which stored events to read back from GITHUB_EVENTS
+type ReplayFilter struct {
+	Subject   string    // server-side subject filter, e.g. github.acme.*
+	StartTime time.Time // deliver from this time, if set
+	StartSeq  uint64    // deliver from this stream sequence, if set
+	Repo      string    // only events for this repository
+	EventType string    // only events of this type
+	Limit     int       // stop after this many matching events (0 = no limit)
+}
+
+// ReplayedEvent is a stored event together with its stream metadata
+type ReplayedEvent struct {
+	Sequence  uint64      `json:"sequence"`
+	Subject   string      `json:"subject"`
+	Published time.Time   `json:"published"`
+	Event     GitHubEvent `json:"event"`
+}
+
+// replayEvents reads stored events with an ephemeral ordered consumer and calls
+// fn for each one matching the filter. Durable consumers are left untouched.
+func replayEvents(ctx context.Context, js jetstream.JetStream, filter ReplayFilter, fn func(ReplayedEvent, jetstream.Msg) error) (int, error) {
+	cfg := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{filter.Subject},
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	}
+	switch {
+	case filter.StartSeq > 0:
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = filter.StartSeq
+	case !filter.StartTime.IsZero():
+		cfg.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		cfg.OptStartTime = &filter.StartTime
+	}
+
+	consumer, err := js.OrderedConsumer(ctx, eventsStream, cfg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create replay consumer: %w", err)
+	}
+
+	count := 0
+	for {
+		info, err := consumer.Info(ctx)
+		if err != nil {
+			return count, fmt.Errorf("failed to get replay consumer info: %w", err)
+		}
+		if info.NumPending == 0 {
+			return count, nil
+		}
+
+		msgs, err := consumer.Fetch(100, jetstream.FetchMaxWait(time.Second))
+		if err != nil {
+			return count, fmt.Errorf("failed to fetch replay messages: %w", err)
+		}
+
+		for msg := range msgs.Messages() {
+			event, ok := decodeReplayed(msg)
+			if !ok || !filter.matches(event) {
+				continue
+			}
+
+			if err := fn(event, msg); err != nil {
+				return count, err
+			}
+
+			count++
+			if filter.Limit > 0 && count >= filter.Limit {
+				return count, nil
+			}
+		}
+		if err := msgs.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+			return count, fmt.Errorf("replay fetch failed: %w", err)
+		}
+	}
+}
+
+// decodeReplayed unpacks a stored message into a ReplayedEvent
+func decodeReplayed(msg jetstream.Msg) (ReplayedEvent, bool) {
+	replayed := ReplayedEvent{Subject: msg.Subject()}
+
+	if meta, err := msg.Metadata(); err == nil {
+		replayed.Sequence = meta.Sequence.Stream
+		replayed.Published = meta.Timestamp.UTC()
+	}
+
+	if err := json.Unmarshal(msg.Data(), &replayed.Event); err != nil {
+		log.Printf("Skipping undecodable event %d on %s: %v", replayed.Sequence, replayed.Subject, err)
+		return replayed, false
+	}
+
+	return replayed, true
+}
+
+// matches applies the client-side repo and event type filters
+func (f ReplayFilter) matches(event ReplayedEvent) bool {
+	if f.Repo != "" && event.Event.Repo != f.Repo {
+		return false
+	}
+	if f.EventType != "" && subjectEventType(event.Subject) != f.EventType {
+		return false
+	}
+	return true
+}
+
+// subjectEventType returns the event type token of github.<org>.<event_type>
+func subjectEventType(subject string) string {
+	return subject[strings.LastIndex(subject, ".")+1:]
+}
+
+// subjectOrg returns the org token of github.<org>.<event_type>
+func subjectOrg(subject string) string {
+	parts := strings.Split(subject, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// runReplay implements the "replay" subcommand
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	since := fs.String("since", "", "Replay from an RFC 3339 time or a duration ago (e.g. 2h)")
+	seq := fs.Uint64("seq", 0, "Replay from this stream sequence")
+	org := fs.String("org", "*", "Only events for this GitHub organization")
+	eventType := fs.String("type", "", "Only events of this type (e.g. template_changed)")
+	repo := fs.String("repo", "", "Only events for this repository")
+	subject := fs.String("subject", "", "Raw subject filter (overrides -org and -type)")
+	format := fs.String("format", "ndjson", "Output format: ndjson or json")
+	feed := fs.Bool("feed", false, "Re-feed events through the controller handlers in dry-run mode and print the plan")
+	limit := fs.Int("limit", 0, "Stop after this many events (0 = no limit)")
+	fs.Parse(args)
+
+	if *format != "ndjson" && *format != "json" {
+		return fmt.Errorf("unknown format %q (want ndjson or json)", *format)
+	}
+
+	filter := ReplayFilter{
+		Subject:   *subject,
+		StartSeq:  *seq,
+		Repo:      *repo,
+		EventType: *eventType,
+		Limit:     *limit,
+	}
+	if filter.Subject == "" {
+		typeToken := "*"
+		if *eventType != "" {
+			typeToken = *eventType
+		}
+		filter.Subject = fmt.Sprintf("github.%s.%s", *org, typeToken)
+	}
+	if *since != "" {
+		start, err := parseSince(*since)
+		if err != nil {
+			return err
+		}
+		filter.StartTime = start
+	}
+
+	config, err := loadNATSConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load NATS configuration: %w", err)
+	}
+	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
+		config.URLs = []string{natsURL}
+	}
+
+	nc, err := connectNATS("github-controller-replay", config)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	js, err := newJetStream(nc, config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		events  = []ReplayedEvent{}
+		metrics = NewMetrics()
+		plan    = dryrun.NewPlan()
+		feeders = make(map[string]*Controller)
+		encoder = json.NewEncoder(os.Stdout)
+	)
+
+	count, err := replayEvents(ctx, js, filter, func(event ReplayedEvent, msg jetstream.Msg) error {
+		if *feed {
+			name := subjectOrg(event.Subject)
+			controller, ok := feeders[name]
+			if !ok {
+				orgCfg, err := loadOrgConfig(name)
+				if err != nil {
+					return err
+				}
+				controller = NewController(orgCfg, nc, js, config, metrics)
+				controller.SetPlan(plan)
+				feeders[name] = controller
+			}
+			log.Printf("⏪ #%d %s", event.Sequence, event.Subject)
+			controller.dispatch(msg.Subject(), msg.Data())
+			return nil
+		}
+
+		if *format == "json" {
+			events = append(events, event)
+			return nil
+		}
+		return encoder.Encode(event)
+	})
+
+	var writeErr error
+	switch {
+	case *feed:
+		writeErr = plan.WriteJSON(os.Stdout)
+	case *format == "json":
+		writeErr = writeJSON(os.Stdout, events)
+	}
+	if writeErr != nil && err == nil {
+		err = writeErr
+	}
+
+	log.Printf("Replayed %d events matching %s", count, filter.Subject)
+	return err
+}
+
+// writeJSON writes v as an indented JSON document
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestSubjectTokens(t *testing.T) {
+	tests := []struct {
+		subject, org, eventType string
+	}{
+		{"github.acme.template_changed", "acme", "template_changed"},
+		{"github.acme-corp.workflow_status", "acme-corp", "workflow_status"},
+		{"github.acme.control.pause", "acme", "pause"},
+		{"github.acme", "", "acme"},
+		{"github", "", "github"},
+	}
+	for _, tt := range tests {
+		if got := subjectOrg(tt.subject); got != tt.org {
+			t.Errorf("subjectOrg(%q) = %q, want %q", tt.subject, got, tt.org)
+		}
+		if got := subjectEventType(tt.subject); got != tt.eventType {
+			t.Errorf("subjectEventType(%q) = %q, want %q", tt.subject, got, tt.eventType)
+		}
+	}
+}
+
+func TestReplayFilterMatches(t *testing.T) {
+	event := ReplayedEvent{
+		Subject: "github.acme.template_changed",
+		Event:   GitHubEvent{Org: "acme", Repo: "widgets"},
+	}
+
+	tests := []struct {
+		name   string
+		filter ReplayFilter
+		want   bool
+	}{
+		{"no filter", ReplayFilter{}, true},
+		{"repo", ReplayFilter{Repo: "widgets"}, true},
+		{"other repo", ReplayFilter{Repo: "gadgets"}, false},
+		{"event type", ReplayFilter{EventType: "template_changed"}, true},
+		{"other event type", ReplayFilter{EventType: "workflow_status"}, false},
+		{"repo and type", ReplayFilter{Repo: "widgets", EventType: "template_changed"}, true},
+		{"repo and other type", ReplayFilter{Repo: "widgets", EventType: "workflow_status"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matches(event); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
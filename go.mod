@@ -5,8 +5,11 @@ go 1.24.0
 toolchain go1.24.4
 
 require (
+	github.com/nats-io/jwt/v2 v2.8.0
 	github.com/nats-io/nats-server/v2 v2.12.2
 	github.com/nats-io/nats.go v1.47.0
+	github.com/nats-io/nkeys v0.4.11
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -14,8 +17,6 @@ require (
 	github.com/google/go-tpm v0.9.6 // indirect
 	github.com/klauspost/compress v1.18.1 // indirect
 	github.com/minio/highwayhash v1.0.4-0.20251030100505-070ab1a87a76 // indirect
-	github.com/nats-io/jwt/v2 v2.8.0 // indirect
-	github.com/nats-io/nkeys v0.4.11 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
@@ -0,0 +1,82 @@
+package dryrun
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Kinds of side effects recorded in a plan
+const (
+	KindNATSPublish   = "nats_publish"
+	KindGitHubAPI     = "github_api"
+	KindTerraformExec = "terraform_exec"
+)
+
+// Action is a side effect that would have been performed
+type Action struct {
+	Kind      string      `json:"kind"`
+	Org       string      `json:"org,omitempty"`
+	Target    string      `json:"target"` // subject, API path or binary
+	Summary   string      `json:"summary"`
+	Payload   interface{} `json:"payload,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Plan collects the actions handlers would have taken in dry-run mode.
+// A nil *Plan means side effects are executed for real.
+type Plan struct {
+	mu      sync.Mutex
+	actions []Action
+}
+
+// NewPlan creates an empty plan
+func NewPlan() *Plan {
+	return &Plan{actions: []Action{}}
+}
+
+// Enabled reports whether side effects should be recorded instead of executed
+func (p *Plan) Enabled() bool {
+	return p != nil
+}
+
+// Record adds an action to the plan
+func (p *Plan) Record(action Action) {
+	if action.Timestamp.IsZero() {
+		action.Timestamp = time.Now().UTC()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.actions = append(p.actions, action)
+}
+
+// Actions returns a copy of the recorded actions in order
+func (p *Plan) Actions() []Action {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	actions := make([]Action, len(p.actions))
+	copy(actions, p.actions)
+	return actions
+}
+
+// WriteJSON writes the plan as an indented JSON document
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		DryRun  bool     `json:"dry_run"`
+		Actions []Action `json:"actions"`
+	}{true, p.Actions()})
+}
+
+// Payload converts a raw message body into a value that renders well in JSON:
+// decoded JSON when the body is JSON, the string otherwise
+func Payload(data []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err == nil {
+		return v
+	}
+	return string(data)
+}
@@ -0,0 +1,180 @@
+package embeddednats
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+)
+
+// Accounts created when Options.Auth is set. GitHub automation and Playwright
+// logging are isolated from each other, mirroring our Synadia Cloud layout.
+const (
+	AccountSystem     = "SYS"
+	AccountGitHub     = "GITHUB"
+	AccountPlaywright = "PLAYWRIGHT"
+)
+
+// authDirName is the directory under the store dir holding keys and creds
+const authDirName = "auth"
+
+// accountUsers maps each generated account to the user its creds file is for
+var accountUsers = []struct {
+	account string
+	user    string
+}{
+	{AccountSystem, "sys"},
+	{AccountGitHub, "controller"},
+	{AccountPlaywright, "playwright"},
+}
+
+// setupAuth switches the server to operator mode with a generated
+// operator/account/user hierarchy. Seeds are created on first start under
+// <store>/auth and reused afterwards, so creds files stay valid across restarts.
+func (e *Server) setupAuth(opts *server.Options, storeDir string) error {
+	dir := filepath.Join(storeDir, authDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create auth directory: %w", err)
+	}
+
+	operator, err := loadOrCreateKey(filepath.Join(dir, "operator.nk"), nkeys.CreateOperator)
+	if err != nil {
+		return err
+	}
+	operatorPub, err := operator.PublicKey()
+	if err != nil {
+		return fmt.Errorf("failed to read operator key: %w", err)
+	}
+
+	resolver := &server.MemAccResolver{}
+	e.creds = make(map[string]string)
+	e.accounts = make(map[string]string)
+	var systemPub string
+
+	for _, au := range accountUsers {
+		name := strings.ToLower(au.account)
+
+		account, err := loadOrCreateKey(filepath.Join(dir, name+".nk"), nkeys.CreateAccount)
+		if err != nil {
+			return err
+		}
+		accountPub, err := account.PublicKey()
+		if err != nil {
+			return fmt.Errorf("failed to read %s account key: %w", au.account, err)
+		}
+
+		e.accounts[au.account] = accountPub
+
+		claims := jwt.NewAccountClaims(accountPub)
+		claims.Name = au.account
+		if au.account != AccountSystem {
+			claims.Limits.JetStreamLimits = jwt.JetStreamLimits{
+				MemoryStorage: jwt.NoLimit,
+				DiskStorage:   jwt.NoLimit,
+				Streams:       jwt.NoLimit,
+				Consumer:      jwt.NoLimit,
+			}
+		} else {
+			systemPub = accountPub
+		}
+
+		accountJWT, err := claims.Encode(operator)
+		if err != nil {
+			return fmt.Errorf("failed to sign %s account: %w", au.account, err)
+		}
+		if err := resolver.Store(accountPub, accountJWT); err != nil {
+			return fmt.Errorf("failed to register %s account: %w", au.account, err)
+		}
+
+		credsFile, err := writeUserCreds(dir, name, au.user, account)
+		if err != nil {
+			return err
+		}
+		e.creds[au.account] = credsFile
+	}
+
+	operatorClaims := jwt.NewOperatorClaims(operatorPub)
+	operatorClaims.Name = "nats-bootstrap"
+	operatorClaims.SystemAccount = systemPub
+	if _, err := operatorClaims.Encode(operator); err != nil {
+		return fmt.Errorf("failed to sign operator: %w", err)
+	}
+
+	opts.TrustedOperators = []*jwt.OperatorClaims{operatorClaims}
+	opts.SystemAccount = systemPub
+	opts.AccountResolver = resolver
+
+	return nil
+}
+
+// writeUserCreds signs a user for an account and writes its .creds file
+func writeUserCreds(dir, name, user string, account nkeys.KeyPair) (string, error) {
+	userKey, err := loadOrCreateKey(filepath.Join(dir, name+"-"+user+".nk"), nkeys.CreateUser)
+	if err != nil {
+		return "", err
+	}
+	userPub, err := userKey.PublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s user key: %w", user, err)
+	}
+	seed, err := userKey.Seed()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s user seed: %w", user, err)
+	}
+
+	claims := jwt.NewUserClaims(userPub)
+	claims.Name = user
+	userJWT, err := claims.Encode(account)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s user: %w", user, err)
+	}
+
+	creds, err := jwt.FormatUserConfig(userJWT, seed)
+	if err != nil {
+		return "", fmt.Errorf("failed to format %s creds: %w", user, err)
+	}
+
+	path := filepath.Join(dir, name+".creds")
+	if err := os.WriteFile(path, creds, 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return path, nil
+}
+
+// loadOrCreateKey reads an NKey seed from path, generating and saving one if missing
+func loadOrCreateKey(path string, create func() (nkeys.KeyPair, error)) (nkeys.KeyPair, error) {
+	if seed, err := os.ReadFile(path); err == nil {
+		kp, err := nkeys.FromSeed(seed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse seed %s: %w", path, err)
+		}
+		return kp, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read seed %s: %w", path, err)
+	}
+
+	kp, err := create()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key for %s: %w", path, err)
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generated seed: %w", err)
+	}
+	if err := os.WriteFile(path, seed, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write seed %s: %w", path, err)
+	}
+
+	return kp, nil
+}
+
+// CredsFile returns the generated .creds file for an account, or "" when
+// auth is not generated by this server
+func (e *Server) CredsFile(account string) string {
+	return e.creds[account]
+}
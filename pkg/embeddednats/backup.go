@@ -0,0 +1,500 @@
+package embeddednats
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Files of one stream in a backup directory, named as by `nats stream backup`
+// (the terraform backup CronJob), so backups restore with either tool
+const (
+	backupMetaFile = "backup.json"
+	backupDataFile = "stream.tar.s2"
+)
+
+const (
+	// snapshotChunkSize is the chunk size for restore uploads
+	snapshotChunkSize = 128 * 1024
+
+	// snapshotTimeout bounds the wait for the next chunk or API reply
+	snapshotTimeout = 30 * time.Second
+)
+
+// ErrStreamExists is returned when restoring a stream that already exists without replace
+var ErrStreamExists = errors.New("stream already exists")
+
+// StreamBackup describes one stream snapshot in a backup directory
+type StreamBackup struct {
+	Stream   string
+	Dir      string
+	Messages uint64
+	Bytes    int64 // compressed snapshot size
+}
+
+// backupMeta is backup.json: the stream config and state at snapshot time,
+// kept verbatim so a restore recreates the stream exactly
+type backupMeta struct {
+	Config json.RawMessage `json:"config"`
+	State  json.RawMessage `json:"state"`
+}
+
+// apiError is the error field of a JetStream API response
+type apiError struct {
+	Code        int    `json:"code"`
+	ErrCode     int    `json:"err_code"`
+	Description string `json:"description"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Description, e.Code)
+}
+
+// snapshotResponse is the reply to a snapshot request
+type snapshotResponse struct {
+	Error  *apiError       `json:"error"`
+	Config json.RawMessage `json:"config"`
+	State  json.RawMessage `json:"state"`
+}
+
+// restoreResponse is the reply to a restore request
+type restoreResponse struct {
+	Error          *apiError `json:"error"`
+	DeliverSubject string    `json:"deliver_subject"`
+}
+
+// restoreResult is the reply to the final, empty restore chunk
+type restoreResult struct {
+	Error *apiError `json:"error"`
+	State struct {
+		Msgs uint64 `json:"messages"`
+	} `json:"state"`
+}
+
+// BackupStreams snapshots the named streams, or every stream (including KV
+// buckets and object stores) when names is empty, into
+// <dir>/<stream>/{backup.json,stream.tar.s2}
+func BackupStreams(ctx context.Context, js jetstream.JetStream, dir string, names ...string) ([]StreamBackup, error) {
+	if len(names) == 0 {
+		lister := js.StreamNames(ctx)
+		for name := range lister.Name() {
+			names = append(names, name)
+		}
+		if err := lister.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list streams: %w", err)
+		}
+		sort.Strings(names)
+	}
+
+	backups := make([]StreamBackup, 0, len(names))
+	for _, name := range names {
+		backup, err := SnapshotStream(ctx, js, name, filepath.Join(dir, name))
+		if err != nil {
+			return backups, err
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// SnapshotStream writes a snapshot of one stream, with its consumers, to dir
+func SnapshotStream(ctx context.Context, js jetstream.JetStream, name, dir string) (StreamBackup, error) {
+	backup := StreamBackup{Stream: name, Dir: dir}
+	nc := js.Conn()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return backup, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	inbox := nc.NewInbox()
+	sub, err := nc.SubscribeSync(inbox)
+	if err != nil {
+		return backup, fmt.Errorf("failed to subscribe for snapshot of %s: %w", name, err)
+	}
+	defer sub.Unsubscribe()
+
+	req, err := json.Marshal(map[string]interface{}{"deliver_subject": inbox})
+	if err != nil {
+		return backup, err
+	}
+
+	var resp snapshotResponse
+	if err := apiRequest(ctx, js, "STREAM.SNAPSHOT."+name, req, &resp); err != nil {
+		return backup, fmt.Errorf("failed to snapshot %s: %w", name, err)
+	}
+	if resp.Error != nil {
+		return backup, fmt.Errorf("failed to snapshot %s: %w", name, resp.Error)
+	}
+
+	data, err := os.Create(filepath.Join(dir, backupDataFile))
+	if err != nil {
+		return backup, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer data.Close()
+
+	for {
+		chunkCtx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+		msg, err := sub.NextMsgWithContext(chunkCtx)
+		cancel()
+		if err != nil {
+			return backup, fmt.Errorf("snapshot of %s stalled after %d bytes: %w", name, backup.Bytes, err)
+		}
+
+		// An empty message ends the snapshot; its status says whether it completed
+		if len(msg.Data) == 0 {
+			if status := msg.Header.Get("Status"); status != "" && status != "204" {
+				return backup, fmt.Errorf("snapshot of %s failed: %s %s", name, status, msg.Header.Get("Description"))
+			}
+			break
+		}
+
+		if _, err := data.Write(msg.Data); err != nil {
+			return backup, fmt.Errorf("failed to write snapshot of %s: %w", name, err)
+		}
+		backup.Bytes += int64(len(msg.Data))
+
+		if msg.Reply != "" {
+			if err := msg.Respond(nil); err != nil {
+				return backup, fmt.Errorf("failed to acknowledge snapshot chunk: %w", err)
+			}
+		}
+	}
+
+	if err := data.Close(); err != nil {
+		return backup, fmt.Errorf("failed to write snapshot of %s: %w", name, err)
+	}
+
+	meta, err := json.MarshalIndent(backupMeta{Config: resp.Config, State: resp.State}, "", "  ")
+	if err != nil {
+		return backup, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, backupMetaFile), meta, 0644); err != nil {
+		return backup, fmt.Errorf("failed to write %s: %w", backupMetaFile, err)
+	}
+
+	var state struct {
+		Msgs uint64 `json:"messages"`
+	}
+	if err := json.Unmarshal(resp.State, &state); err == nil {
+		backup.Messages = state.Msgs
+	}
+
+	return backup, nil
+}
+
+// RestoreStreams restores every stream snapshot found in dir, or dir itself
+// when it is a single stream snapshot. Streams that already exist are an error
+// unless replace is set, which deletes them first.
+func RestoreStreams(ctx context.Context, js jetstream.JetStream, dir string, replace bool) ([]StreamBackup, error) {
+	dirs, err := snapshotDirs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []StreamBackup
+	for _, streamDir := range dirs {
+		backup, err := RestoreStream(ctx, js, streamDir, replace)
+		if err != nil {
+			return restored, err
+		}
+		restored = append(restored, backup)
+	}
+	return restored, nil
+}
+
+// snapshotDirs returns dir when it is a stream snapshot, or the stream
+// snapshots directly inside it
+func snapshotDirs(dir string) ([]string, error) {
+	if _, err := os.Stat(filepath.Join(dir, backupMetaFile)); err == nil {
+		return []string{dir}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		streamDir := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(streamDir, backupMetaFile)); err != nil {
+			continue
+		}
+		dirs = append(dirs, streamDir)
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no stream backups (%s) in %s", backupMetaFile, dir)
+	}
+	return dirs, nil
+}
+
+// RestoreStream restores one stream snapshot directory written by SnapshotStream
+// or `nats stream backup`
+func RestoreStream(ctx context.Context, js jetstream.JetStream, dir string, replace bool) (StreamBackup, error) {
+	backup := StreamBackup{Dir: dir}
+	nc := js.Conn()
+
+	raw, err := os.ReadFile(filepath.Join(dir, backupMetaFile))
+	if err != nil {
+		return backup, fmt.Errorf("failed to read backup metadata: %w", err)
+	}
+	var meta backupMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return backup, fmt.Errorf("invalid %s in %s: %w", backupMetaFile, dir, err)
+	}
+	var config struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(meta.Config, &config); err != nil || config.Name == "" {
+		return backup, fmt.Errorf("%s in %s has no stream name", backupMetaFile, dir)
+	}
+	backup.Stream = config.Name
+
+	if _, err := js.Stream(ctx, config.Name); err == nil {
+		if !replace {
+			return backup, fmt.Errorf("%w: %s", ErrStreamExists, config.Name)
+		}
+		if err := js.DeleteStream(ctx, config.Name); err != nil {
+			return backup, fmt.Errorf("failed to delete existing stream %s: %w", config.Name, err)
+		}
+	} else if !errors.Is(err, jetstream.ErrStreamNotFound) {
+		return backup, fmt.Errorf("failed to look up stream %s: %w", config.Name, err)
+	}
+
+	data, err := os.Open(filepath.Join(dir, backupDataFile))
+	if err != nil {
+		return backup, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer data.Close()
+
+	var resp restoreResponse
+	if err := apiRequest(ctx, js, "STREAM.RESTORE."+config.Name, raw, &resp); err != nil {
+		return backup, fmt.Errorf("failed to restore %s: %w", config.Name, err)
+	}
+	if resp.Error != nil {
+		return backup, fmt.Errorf("failed to restore %s: %w", config.Name, resp.Error)
+	}
+
+	chunk := make([]byte, snapshotChunkSize)
+	for {
+		n, err := data.Read(chunk)
+		if n > 0 {
+			reply, err := request(ctx, nc, resp.DeliverSubject, chunk[:n])
+			if err != nil {
+				return backup, fmt.Errorf("failed to send snapshot of %s: %w", config.Name, err)
+			}
+			if len(reply.Data) > 0 {
+				return backup, fmt.Errorf("restore of %s failed: %s", config.Name, reply.Data)
+			}
+			backup.Bytes += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return backup, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+	}
+
+	// An empty chunk finishes the upload; the reply comes once the stream is restored
+	reply, err := request(ctx, nc, resp.DeliverSubject, nil)
+	if err != nil {
+		return backup, fmt.Errorf("failed to finish restore of %s: %w", config.Name, err)
+	}
+	var result restoreResult
+	if err := json.Unmarshal(reply.Data, &result); err != nil {
+		return backup, fmt.Errorf("invalid restore reply for %s: %w", config.Name, err)
+	}
+	if result.Error != nil {
+		return backup, fmt.Errorf("restore of %s failed: %w", config.Name, result.Error)
+	}
+	backup.Messages = result.State.Msgs
+
+	return backup, nil
+}
+
+// apiRequest sends a JetStream API request, honouring the context's domain or prefix
+func apiRequest(ctx context.Context, js jetstream.JetStream, subject string, req []byte, resp interface{}) error {
+	msg, err := request(ctx, js.Conn(), apiPrefix(js)+subject, req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(msg.Data, resp); err != nil {
+		return fmt.Errorf("invalid API response: %w", err)
+	}
+	return nil
+}
+
+// request is a NATS request bounded by snapshotTimeout
+func request(ctx context.Context, nc *nats.Conn, subject string, data []byte) (*nats.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+	return nc.RequestWithContext(ctx, subject, data)
+}
+
+// apiPrefix returns the JetStream API subject prefix used by js
+func apiPrefix(js jetstream.JetStream) string {
+	opts := js.Options()
+	switch {
+	case opts.Domain != "":
+		return fmt.Sprintf("$JS.%s.API.", opts.Domain)
+	case opts.APIPrefix != "":
+		return strings.TrimSuffix(opts.APIPrefix, ".") + "."
+	default:
+		return jetstream.DefaultAPIPrefix
+	}
+}
+
+// WriteBackupArchive writes the backup directory dir as a gzipped tar to w
+func WriteBackupArchive(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", dir, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return gz.Close()
+}
+
+// ExtractBackupArchive unpacks a gzipped tar written by WriteBackupArchive into dir
+func ExtractBackupArchive(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %q escapes the backup directory", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", target, err)
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+			}
+		}
+	}
+}
+
+// restoreOnStart restores Options.RestoreDir before the spec is provisioned,
+// so provisioning updates the restored streams instead of creating empty ones.
+// Streams already in the store are kept with a warning, or replaced with
+// Options.RestoreReplace.
+func (e *Server) restoreOnStart() error {
+	if e.cfg.RestoreDir == "" {
+		return nil
+	}
+
+	dirs, err := snapshotDirs(e.cfg.RestoreDir)
+	if err != nil {
+		return err
+	}
+
+	nc, err := e.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	for _, dir := range dirs {
+		backup, err := RestoreStream(context.Background(), js, dir, e.cfg.RestoreReplace)
+		if errors.Is(err, ErrStreamExists) {
+			e.logf("⚠️ Warning: not restoring %s: the stream already exists (replace it with -restore-replace)", backup.Stream)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		e.logf("♻️ Restored %s (%d messages)", backup.Stream, backup.Messages)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package embeddednats_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeblew999/.github/pkg/embeddednats"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestRestoreOnStartIntoExistingStore(t *testing.T) {
+	cfg := testOptions(t, "restore")
+	cfg.SeedDir = "embeddednatstest/testdata"
+	backupDir := filepath.Join(t.TempDir(), "backup")
+
+	// Back up the 3 seed events, then add one more to the store
+	s := startServer(t, cfg)
+	streamSubjects(t, s, "GITHUB_EVENTS", 3)
+	nc, err := s.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embeddednats.BackupStreams(context.Background(), js, backupDir, "GITHUB_EVENTS"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.Publish(context.Background(), "github.acme.template_changed", []byte(`{"org":"acme"}`)); err != nil {
+		t.Fatal(err)
+	}
+	nc.Close()
+	s.Stop()
+
+	cfg.SeedDir = ""
+	cfg.RestoreDir = backupDir
+	tests := []struct {
+		replace bool
+		want    uint64
+	}{
+		{false, 4}, // the existing stream is kept
+		{true, 3},  // and replaced by the backup
+	}
+	for _, tt := range tests {
+		cfg.RestoreReplace = tt.replace
+		s := startServer(t, cfg)
+		if got := streamMsgs(t, s, "GITHUB_EVENTS"); got != tt.want {
+			t.Errorf("restore-replace %v: GITHUB_EVENTS holds %d messages, want %d", tt.replace, got, tt.want)
+		}
+		s.Stop()
+	}
+}
+
+// streamMsgs returns the number of messages in stream
+func streamMsgs(t *testing.T, s *embeddednats.Server, stream string) uint64 {
+	t.Helper()
+	nc, err := s.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	str, err := js.Stream(context.Background(), stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return str.CachedInfo().State.Msgs
+}
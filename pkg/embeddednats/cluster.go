@@ -0,0 +1,275 @@
+package embeddednats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultClusterPort is the route port of the first cluster node
+const DefaultClusterPort = 6222
+
+// clusterName is the NATS cluster name used for local clusters
+const clusterName = "nats-bootstrap"
+
+// Cluster is a set of in-process NATS servers joined by routes with
+// clustered JetStream, for testing replica failover locally
+type Cluster struct {
+	nodes   []*Server
+	cfg     Options
+	tempDir string
+}
+
+// NewCluster creates size servers. Node i listens on Port+i, HTTPPort+i and
+// ClusterPort+i (random ports when the base is 0) and stores JetStream data in
+// <store>/node-<i>; generated auth and TLS material is shared by all nodes.
+func NewCluster(cfg Options, size, clusterPort int) (*Cluster, error) {
+	if size < 2 {
+		return nil, fmt.Errorf("cluster size must be at least 2, got %d", size)
+	}
+	if cfg.RestoreDir != "" {
+		return nil, fmt.Errorf("restoring on start is not supported in cluster mode; restore into the running cluster instead")
+	}
+	if cfg.Logf == nil {
+		cfg.Logf = log.Printf
+	}
+
+	c := &Cluster{cfg: cfg}
+
+	storeDir := cfg.StoreDir
+	if storeDir == "" {
+		tempDir, err := os.MkdirTemp("", "nats-bootstrap-cluster-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		c.tempDir = tempDir
+		storeDir = tempDir
+	}
+
+	ports, err := clusterPorts(cfg.Host, clusterPort, size)
+	if err != nil {
+		c.cleanup()
+		return nil, err
+	}
+
+	var routes []*url.URL
+	for _, port := range ports {
+		routes = append(routes, &url.URL{Scheme: "nats-route", Host: net.JoinHostPort(cfg.Host, strconv.Itoa(port))})
+	}
+
+	for i := 0; i < size; i++ {
+		nodeCfg := cfg
+		nodeCfg.ServerName = fmt.Sprintf("%s-%d", cfg.ServerName, i+1)
+		nodeCfg.StoreDir = filepath.Join(storeDir, fmt.Sprintf("node-%d", i+1))
+		if cfg.Port > 0 {
+			nodeCfg.Port = cfg.Port + i
+		}
+		if cfg.HTTPPort > 0 {
+			nodeCfg.HTTPPort = cfg.HTTPPort + i
+		}
+		if i > 0 {
+			nodeCfg.StatusPort = -1 // the first node reports for the cluster
+		}
+		prefix := fmt.Sprintf("[%s] ", nodeCfg.ServerName)
+		nodeCfg.Logf = func(format string, args ...interface{}) {
+			cfg.Logf(prefix+format, args...)
+		}
+
+		node, err := newServer(nodeCfg, &clusterSpec{
+			name:    clusterName,
+			port:    ports[i],
+			routes:  routes,
+			keysDir: storeDir,
+			size:    size,
+		})
+		if err != nil {
+			c.cleanup()
+			return nil, fmt.Errorf("failed to create node %d: %w", i+1, err)
+		}
+		if i > 0 {
+			node.ready = c.nodes[0].ready
+		}
+		c.nodes = append(c.nodes, node)
+	}
+
+	return c, nil
+}
+
+// clusterPorts returns the route port of each node, picking free ports when base is 0
+func clusterPorts(host string, base, size int) ([]int, error) {
+	ports := make([]int, size)
+	for i := range ports {
+		if base > 0 {
+			ports[i] = base + i
+			continue
+		}
+
+		l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find a free cluster port: %w", err)
+		}
+		ports[i] = l.Addr().(*net.TCPAddr).Port
+		l.Close()
+	}
+	return ports, nil
+}
+
+// Start starts every node, waits for the JetStream meta leader and provisions
+// the GitHub streams with replicas spread across the cluster
+func (c *Cluster) Start() error {
+	first := c.nodes[0]
+	if err := first.startStatusServer(); err != nil {
+		return err
+	}
+
+	if err := c.startNodes(); err != nil {
+		return err
+	}
+	first.ready.mark(StageServer, nil, c.GetConnectionURL())
+
+	err := c.waitForStreams(30 * time.Second)
+	first.ready.mark(StageStreams, err, fmt.Sprintf("R%d", first.replicas))
+	if err != nil {
+		c.cfg.Logf("⚠️ Warning: failed to setup GitHub streams: %v", err)
+	} else {
+		c.cfg.Logf("✅ GitHub event streams configured (R%d)", first.replicas)
+	}
+
+	first.seedOnStart()
+	go first.publishHealth(first.ctx)
+
+	return nil
+}
+
+// startNodes starts every node, stopping the cluster if one fails
+func (c *Cluster) startNodes() error {
+	for _, node := range c.nodes {
+		if err := node.startServer(); err != nil {
+			c.Stop()
+			return err
+		}
+	}
+	return nil
+}
+
+// provisionAttemptTimeout bounds each provisioning attempt while the cluster
+// starts; JetStream API requests go unanswered until a meta leader is elected
+const provisionAttemptTimeout = 5 * time.Second
+
+// waitForStreams retries stream provisioning until the cluster has elected a
+// JetStream meta leader
+func (c *Cluster) waitForStreams(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), provisionAttemptTimeout)
+		err := c.nodes[0].Provision(ctx, c.nodes[0].jsSpec)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// Stop stops every node and removes the temporary store directory
+func (c *Cluster) Stop() {
+	for _, node := range c.nodes {
+		if node.server.Running() {
+			node.Stop()
+		}
+	}
+	c.cleanup()
+}
+
+// cleanup removes the temporary store directory; persistent store dirs are kept
+func (c *Cluster) cleanup() {
+	if c.tempDir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(c.tempDir); err != nil {
+		c.cfg.Logf("Warning: failed to cleanup temp directory: %v", err)
+	}
+}
+
+// Nodes returns the cluster members, e.g. to stop one and exercise failover
+func (c *Cluster) Nodes() []*Server {
+	return c.nodes
+}
+
+// RestartNode stops node i and starts it again on the same ports and store,
+// to exercise stream leader failover and client reconnects
+func (c *Cluster) RestartNode(i int) error {
+	if i < 0 || i >= len(c.nodes) {
+		return fmt.Errorf("no cluster node %d", i+1)
+	}
+
+	old := c.nodes[i]
+	cfg := old.cfg
+	if addr, ok := old.server.Addr().(*net.TCPAddr); ok {
+		cfg.Port = addr.Port
+	}
+	if addr := old.server.MonitorAddr(); addr != nil {
+		cfg.HTTPPort = addr.Port
+	}
+	if u, err := url.Parse(old.GetStatusURL()); err == nil && u.Port() != "" {
+		cfg.StatusPort, _ = strconv.Atoi(u.Port())
+	}
+	if old.server.Running() {
+		old.Stop()
+	}
+
+	node, err := newServer(cfg, old.spec)
+	if err != nil {
+		return fmt.Errorf("failed to recreate node %d: %w", i+1, err)
+	}
+	// Every node keeps sharing the first node's readiness tracker
+	node.ready = old.ready
+
+	// The first node reports for the cluster, so its replacement serves
+	// /readyz and /health and publishes health events again
+	if err := node.startStatusServer(); err != nil {
+		return err
+	}
+	if err := node.startServer(); err != nil {
+		node.stopStatusServer()
+		return err
+	}
+	if i == 0 {
+		go node.publishHealth(node.ctx)
+	}
+
+	c.nodes[i] = node
+	return nil
+}
+
+// GetConnectionURLs returns the client URL of every node
+func (c *Cluster) GetConnectionURLs() []string {
+	urls := make([]string, len(c.nodes))
+	for i, node := range c.nodes {
+		urls[i] = node.GetConnectionURL()
+	}
+	return urls
+}
+
+// GetConnectionURL returns all client URLs as a comma-separated server list
+func (c *Cluster) GetConnectionURL() string {
+	return strings.Join(c.GetConnectionURLs(), ",")
+}
+
+// Connect opens a client connection that can fail over between all nodes
+func (c *Cluster) Connect(opts ...nats.Option) (*nats.Conn, error) {
+	return c.nodes[0].connectTo(c.GetConnectionURL(), opts...)
+}
@@ -0,0 +1,71 @@
+package embeddednats_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/embeddednats"
+)
+
+func TestRestartNodeKeepsClusterStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts three servers")
+	}
+
+	cfg := embeddednats.DefaultOptions()
+	cfg.Port = 0
+	cfg.HTTPPort = -1
+	cfg.StatusPort = 0
+	cfg.Org = "acme"
+	cfg.HealthInterval = 100 * time.Millisecond
+	cfg.StoreDir = t.TempDir()
+	cfg.ServerName = "nats-test"
+	cfg.Logf = t.Logf
+
+	c, err := embeddednats.NewCluster(cfg, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+
+	statusURL := c.Nodes()[0].GetStatusURL()
+	if err := c.RestartNode(0); err != nil {
+		t.Fatalf("RestartNode(0): %v", err)
+	}
+	if got := c.Nodes()[0].GetStatusURL(); got != statusURL {
+		t.Errorf("status URL after restart = %s, want %s", got, statusURL)
+	}
+
+	for _, path := range []string{"/readyz", "/health"} {
+		resp, err := http.Get(statusURL + path)
+		if err != nil {
+			t.Fatalf("GET %s after restart: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s after restart = %d, want 200", path, resp.StatusCode)
+		}
+	}
+	for i, node := range c.Nodes() {
+		if !node.Readiness().Ready {
+			t.Errorf("node %d not ready after restarting node 1", i+1)
+		}
+	}
+
+	nc, err := c.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	sub, err := nc.SubscribeSync(embeddednats.HealthSubject("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.NextMsg(5 * time.Second); err != nil {
+		t.Errorf("no health event after restarting node 1: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package embeddednatstest starts embedded NATS servers and clusters for
+// integration tests, so that the embeddednats package itself does not depend
+// on the testing package.
+package embeddednatstest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joeblew999/.github/pkg/embeddednats"
+	"github.com/nats-io/nats.go"
+)
+
+// options returns settings for a test server: random client port, monitoring,
+// status server and health events disabled, JetStream stored in t.TempDir()
+func options(t testing.TB) embeddednats.Options {
+	cfg := embeddednats.DefaultOptions()
+	cfg.Port = 0
+	cfg.HTTPPort = -1
+	cfg.StatusPort = -1
+	cfg.HealthInterval = 0
+	cfg.StoreDir = t.TempDir()
+	cfg.ServerName = "nats-test"
+	cfg.Logf = t.Logf
+	return cfg
+}
+
+// StartTestServer starts a server for a test with the GitHub streams
+// provisioned. The server is stopped automatically when the test finishes.
+func StartTestServer(t testing.TB) *embeddednats.Server {
+	t.Helper()
+
+	s, err := embeddednats.New(options(t))
+	if err != nil {
+		t.Fatalf("embeddednats: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("embeddednats: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	requireStreams(t, s)
+
+	return s
+}
+
+// StartTestCluster starts a cluster of size nodes on random ports with R<size>
+// GitHub streams (R3 for larger clusters), stopped automatically when the test
+// finishes
+func StartTestCluster(t testing.TB, size int) *embeddednats.Cluster {
+	t.Helper()
+
+	c, err := embeddednats.NewCluster(options(t), size, 0)
+	if err != nil {
+		t.Fatalf("embeddednats: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("embeddednats: %v", err)
+	}
+	t.Cleanup(c.Stop)
+	requireStreams(t, c.Nodes()[0])
+
+	return c
+}
+
+// requireStreams fails the test when Start could not provision the streams,
+// which it only logs as a warning
+func requireStreams(t testing.TB, s *embeddednats.Server) {
+	t.Helper()
+
+	if check := s.Readiness().Checks[embeddednats.StageStreams]; !check.Ready {
+		t.Fatalf("embeddednats: failed to provision GitHub streams: %s", check.Error)
+	}
+}
+
+// Seed publishes the NDJSON fixtures in dir to a test server at once and
+// returns how many were published
+func Seed(t testing.TB, s *embeddednats.Server, dir string) int {
+	t.Helper()
+
+	events, err := embeddednats.LoadSeedDir(dir)
+	if err != nil {
+		t.Fatalf("embeddednats: %v", err)
+	}
+
+	n, err := s.Seed(context.Background(), events, 0)
+	if err != nil {
+		t.Fatalf("embeddednats: failed to seed: %v", err)
+	}
+
+	return n
+}
+
+// Connect connects to a test server and closes the connection when the test finishes
+func Connect(t testing.TB, s *embeddednats.Server, opts ...nats.Option) *nats.Conn {
+	t.Helper()
+
+	nc, err := s.Connect(opts...)
+	if err != nil {
+		t.Fatalf("embeddednats: failed to connect: %v", err)
+	}
+	t.Cleanup(nc.Close)
+
+	return nc
+}
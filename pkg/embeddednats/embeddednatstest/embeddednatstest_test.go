@@ -0,0 +1,87 @@
+package embeddednatstest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestStartTestServerProvisionsStreams(t *testing.T) {
+	s := StartTestServer(t)
+	js, err := jetstream.New(Connect(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, name := range []string{"GITHUB_EVENTS", "WORKFLOW_COORDINATION"} {
+		if _, err := js.Stream(ctx, name); err != nil {
+			t.Errorf("stream %s: %v", name, err)
+		}
+	}
+
+	if _, err := js.Publish(ctx, "github.acme.template_changed", []byte(`{"org":"acme"}`)); err != nil {
+		t.Fatalf("publish to GITHUB_EVENTS: %v", err)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	s := StartTestServer(t)
+	if n := Seed(t, s, "testdata"); n != 3 {
+		t.Fatalf("Seed published %d events, want 3", n)
+	}
+
+	js, err := jetstream.New(Connect(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := js.Stream(ctx, "GITHUB_EVENTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := stream.Info(ctx, jetstream.WithSubjectFilter("github.>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 3 {
+		t.Errorf("GITHUB_EVENTS holds %d messages, want 3", info.State.Msgs)
+	}
+	for _, subject := range []string{"github.acme.template_changed", "github.acme.regeneration_requested", "github.acme.workflow_status"} {
+		if info.State.Subjects[subject] != 1 {
+			t.Errorf("GITHUB_EVENTS holds %d messages on %s, want 1", info.State.Subjects[subject], subject)
+		}
+	}
+}
+
+func TestStartTestCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts three servers")
+	}
+
+	c := StartTestCluster(t, 3)
+	nc, err := c.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := js.Stream(ctx, "GITHUB_EVENTS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas := stream.CachedInfo().Config.Replicas; replicas != 3 {
+		t.Errorf("GITHUB_EVENTS has %d replicas, want 3", replicas)
+	}
+}
@@ -0,0 +1,373 @@
+package embeddednats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// DefaultStatusPort serves the bootstrap's readiness and health over HTTP
+const DefaultStatusPort = 8223
+
+// Startup stages reported by Readiness
+const (
+	StageServer  = "server"  // accepting client connections
+	StageStreams = "streams" // JetStream spec provisioned (and backup restored)
+	StageSeed    = "seed"    // seed fixtures published
+)
+
+// NATSDeploymentType and NATSConnectionStatus values from
+// schemas/github_events.proto, as protojson spells them
+const (
+	deploymentSelfHosted   = "NATS_DEPLOYMENT_TYPE_SELF_HOSTED"
+	connectionConnected    = "NATS_CONNECTION_STATUS_CONNECTED"
+	connectionDisconnected = "NATS_CONNECTION_STATUS_DISCONNECTED"
+)
+
+// storageWarnRatio is the JetStream store usage that raises a health warning
+const storageWarnRatio = 0.9
+
+// Check is the state of one startup stage
+type Check struct {
+	Ready  bool      `json:"ready"`
+	Detail string    `json:"detail,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// Readiness is the bootstrap's startup progress, served on /readyz
+type Readiness struct {
+	Ready  bool             `json:"ready"`
+	Checks map[string]Check `json:"checks"`
+}
+
+// readiness tracks the startup stages; cluster nodes share one
+type readiness struct {
+	mu     sync.Mutex
+	checks map[string]Check
+}
+
+// newReadiness returns a tracker with every stage pending
+func newReadiness() *readiness {
+	now := time.Now().UTC()
+	return &readiness{checks: map[string]Check{
+		StageServer:  {Since: now},
+		StageStreams: {Since: now},
+		StageSeed:    {Since: now},
+	}}
+}
+
+// mark records the outcome of a stage
+func (r *readiness) mark(stage string, err error, detail string) {
+	check := Check{Ready: err == nil, Detail: detail, Since: time.Now().UTC()}
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.checks[stage] = check
+	r.mu.Unlock()
+}
+
+// pending records progress on a stage that has not completed yet
+func (r *readiness) pending(stage, detail string) {
+	r.mu.Lock()
+	r.checks[stage] = Check{Detail: detail, Since: time.Now().UTC()}
+	r.mu.Unlock()
+}
+
+// snapshot returns the current readiness
+func (r *readiness) snapshot() Readiness {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ready := Readiness{Ready: true, Checks: make(map[string]Check, len(r.checks))}
+	for stage, check := range r.checks {
+		ready.Checks[stage] = check
+		if !check.Ready {
+			ready.Ready = false
+		}
+	}
+	return ready
+}
+
+// Readiness reports which startup stages have completed
+func (e *Server) Readiness() Readiness {
+	return e.ready.snapshot()
+}
+
+// NATSHealthEvent is the JSON form of NATSHealthEvent in schemas/github_events.proto
+type NATSHealthEvent struct {
+	Org              string                 `json:"org"`
+	DeploymentID     string                 `json:"deployment_id"`
+	DeploymentType   string                 `json:"deployment_type"`
+	Timestamp        time.Time              `json:"timestamp"`
+	ConnectionStatus string                 `json:"connection_status"`
+	Performance      NATSPerformanceMetrics `json:"performance"`
+	ClusterInfo      *NATSClusterInfo       `json:"cluster_info,omitempty"`
+	Errors           []NATSError            `json:"errors,omitempty"`
+}
+
+// NATSPerformanceMetrics is the server traffic section of a NATSHealthEvent
+type NATSPerformanceMetrics struct {
+	MessagesIn    int64 `json:"messages_in"`
+	MessagesOut   int64 `json:"messages_out"`
+	BytesIn       int64 `json:"bytes_in"`
+	BytesOut      int64 `json:"bytes_out"`
+	SlowConsumers int64 `json:"slow_consumers"`
+}
+
+// NATSClusterInfo is the JetStream meta cluster section of a NATSHealthEvent
+type NATSClusterInfo struct {
+	ClusterName string         `json:"cluster_name"`
+	Nodes       []NATSNodeInfo `json:"nodes"`
+	LeaderNode  string         `json:"leader_node"`
+	Health      string         `json:"health"`
+}
+
+// NATSNodeInfo is one JetStream peer in NATSClusterInfo
+type NATSNodeInfo struct {
+	ServerName string    `json:"server_name"`
+	IsLeader   bool      `json:"is_leader"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// NATSError is a problem reported in a NATSHealthEvent
+type NATSError struct {
+	ErrorCode    string    `json:"error_code"`
+	ErrorMessage string    `json:"error_message"`
+	Component    string    `json:"component"`
+	Severity     string    `json:"severity"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// HealthSubject is where health events for org are published
+func HealthSubject(org string) string {
+	return fmt.Sprintf("system.%s.nats_health", org)
+}
+
+// HealthEvent builds a NATSHealthEvent for org from the server's varz, jsz and routez
+// data and the startup readiness
+func (e *Server) HealthEvent(org string) (*NATSHealthEvent, error) {
+	now := time.Now().UTC()
+
+	varz, err := e.server.Varz(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read varz: %w", err)
+	}
+	jsz, err := e.server.Jsz(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jsz: %w", err)
+	}
+
+	event := &NATSHealthEvent{
+		Org:              org,
+		DeploymentID:     varz.Name,
+		DeploymentType:   deploymentSelfHosted,
+		Timestamp:        now,
+		ConnectionStatus: connectionConnected,
+		Performance: NATSPerformanceMetrics{
+			MessagesIn:    varz.InMsgs,
+			MessagesOut:   varz.OutMsgs,
+			BytesIn:       varz.InBytes,
+			BytesOut:      varz.OutBytes,
+			SlowConsumers: varz.SlowConsumers,
+		},
+	}
+	if !e.server.Running() {
+		event.ConnectionStatus = connectionDisconnected
+	}
+
+	addError := func(code, component, severity, format string, args ...interface{}) {
+		event.Errors = append(event.Errors, NATSError{
+			ErrorCode:    code,
+			ErrorMessage: fmt.Sprintf(format, args...),
+			Component:    component,
+			Severity:     severity,
+			Timestamp:    now,
+		})
+	}
+
+	for stage, check := range e.Readiness().Checks {
+		if check.Error != "" {
+			addError(stage+"_failed", "bootstrap", "ERROR", "%s", check.Error)
+		}
+	}
+
+	if jsz.Disabled {
+		addError("jetstream_disabled", "jetstream", "CRITICAL", "JetStream is disabled")
+	}
+	if jsz.Config.MaxStore > 0 && float64(jsz.Store) >= storageWarnRatio*float64(jsz.Config.MaxStore) {
+		addError("jetstream_storage", "jetstream", "WARNING", "JetStream store %d of %d bytes used", jsz.Store, jsz.Config.MaxStore)
+	}
+	if varz.SlowConsumers > 0 {
+		addError("slow_consumers", "server", "WARNING", "%d slow consumers", varz.SlowConsumers)
+	}
+
+	if meta := jsz.Meta; meta != nil && e.spec != nil {
+		routez, err := e.server.Routez(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read routez: %w", err)
+		}
+		event.ClusterInfo = clusterInfo(meta, varz.Name, routez.Routes, now)
+		if event.ClusterInfo.Health != "HEALTHY" {
+			addError("cluster_"+strings.ToLower(event.ClusterInfo.Health), "jetstream", "WARNING", "JetStream meta cluster %s is %s", meta.Name, event.ClusterInfo.Health)
+		}
+	}
+
+	return event, nil
+}
+
+// clusterInfo summarises the JetStream meta cluster as seen from self; peers
+// come from the routes, which every node knows, rather than the meta replicas
+// only the leader reports
+func clusterInfo(meta *server.MetaClusterInfo, self string, routes []*server.RouteInfo, now time.Time) *NATSClusterInfo {
+	info := &NATSClusterInfo{
+		ClusterName: meta.Name,
+		LeaderNode:  meta.Leader,
+		Nodes:       []NATSNodeInfo{{ServerName: self, IsLeader: self == meta.Leader, LastSeen: now}},
+		Health:      "HEALTHY",
+	}
+
+	// Pooled routes connect each peer several times; keep its latest activity
+	seen := map[string]int{self: 0}
+	for _, route := range routes {
+		if i, ok := seen[route.RemoteName]; ok {
+			if route.LastActivity.After(info.Nodes[i].LastSeen) {
+				info.Nodes[i].LastSeen = route.LastActivity
+			}
+			continue
+		}
+		seen[route.RemoteName] = len(info.Nodes)
+		info.Nodes = append(info.Nodes, NATSNodeInfo{
+			ServerName: route.RemoteName,
+			IsLeader:   route.RemoteName == meta.Leader,
+			LastSeen:   route.LastActivity,
+		})
+	}
+
+	switch {
+	case meta.Leader == "":
+		info.Health = "UNHEALTHY"
+	case len(info.Nodes) < meta.Size:
+		info.Health = "DEGRADED"
+	}
+	return info
+}
+
+// publishHealth publishes a health event every HealthInterval until ctx is done
+func (e *Server) publishHealth(ctx context.Context) {
+	org := e.cfg.Org
+	if org == "" || e.cfg.HealthInterval <= 0 {
+		return
+	}
+
+	nc, err := e.Connect()
+	if err != nil {
+		e.logf("⚠️ Warning: health events disabled: failed to connect: %v", err)
+		return
+	}
+	defer nc.Close()
+
+	subject := HealthSubject(org)
+	e.logf("💓 Publishing health events to %s every %s", subject, e.cfg.HealthInterval)
+
+	ticker := time.NewTicker(e.cfg.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		event, err := e.HealthEvent(org)
+		if err == nil {
+			var data []byte
+			if data, err = json.Marshal(event); err == nil {
+				err = nc.Publish(subject, data)
+			}
+		}
+		if err != nil {
+			e.logf("⚠️ Warning: failed to publish health event: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startStatusServer serves /readyz, /healthz and /health on StatusPort
+func (e *Server) startStatusServer() error {
+	if e.cfg.StatusPort < 0 {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.StatusPort)))
+	if err != nil {
+		return fmt.Errorf("failed to listen for status requests: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ready := e.Readiness()
+		status := http.StatusOK
+		if !ready.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeStatusJSON(w, status, ready)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !e.server.Running() {
+			http.Error(w, "nats server not running", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		org := r.URL.Query().Get("org")
+		if org == "" {
+			org = e.cfg.Org
+		}
+		event, err := e.HealthEvent(org)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		writeStatusJSON(w, http.StatusOK, event)
+	})
+
+	e.status = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	e.statusURL = "http://" + l.Addr().String()
+	go e.status.Serve(l)
+
+	return nil
+}
+
+// stopStatusServer closes the status listener
+func (e *Server) stopStatusServer() {
+	if e.status != nil {
+		e.status.Close()
+		e.status = nil
+	}
+}
+
+// GetStatusURL returns the readiness and health HTTP URL, or "" when disabled
+func (e *Server) GetStatusURL() string {
+	return e.statusURL
+}
+
+// writeStatusJSON writes v as an indented JSON response
+func writeStatusJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
@@ -0,0 +1,170 @@
+package embeddednats
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Defaults for leafnode mode
+const (
+	DefaultLeafDomain = "leaf"
+	DefaultHubDomain  = "hub"
+)
+
+// How GITHUB_EVENTS follows the hub's stream in leafnode mode
+const (
+	// SyncSource keeps a local stream and sources the hub's events into it,
+	// so local work continues offline and hub events catch up on reconnect.
+	// Events published on the leaf stay local unless EventsUpstream is set.
+	SyncSource = "source"
+
+	// SyncMirror makes the local stream a read-only mirror of the hub's
+	SyncMirror = "mirror"
+)
+
+// upstreamStreamName holds the events published on a leaf with
+// EventsUpstream, until the hub sources them from the leaf's domain
+const upstreamStreamName = "GITHUB_EVENTS_UPSTREAM"
+
+// eventSubjects are the GitHub event subjects captured by GITHUB_EVENTS,
+// kept off the leaf connection
+const eventSubjects = "github.*.*"
+
+// setupLeaf connects the server to a hub as a leafnode with its own
+// JetStream domain. Event subjects are not bridged over the leaf connection;
+// they reach the local stream through JetStream sourcing instead, so nothing
+// is stored twice and sync resumes where it left off after being offline.
+func (e *Server) setupLeaf(opts *server.Options) error {
+	cfg := e.cfg
+
+	switch cfg.EventsSync {
+	case SyncSource, SyncMirror:
+	default:
+		return fmt.Errorf("unknown events sync mode %q (want %s or %s)", cfg.EventsSync, SyncSource, SyncMirror)
+	}
+
+	var urls []*url.URL
+	secure := false
+	for _, raw := range strings.Split(cfg.LeafURL, ",") {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid leafnode URL %q: %w", raw, err)
+		}
+		if u.Scheme == "tls" {
+			secure = true
+		}
+		urls = append(urls, u)
+	}
+
+	remote := &server.RemoteLeafOpts{
+		URLs:        urls,
+		Credentials: cfg.LeafCredsFile,
+		DenyImports: []string{eventSubjects},
+		DenyExports: []string{eventSubjects},
+	}
+	if secure {
+		remote.TLS = true
+		remote.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if account := e.accounts[AccountGitHub]; account != "" {
+		remote.LocalAccount = account
+	}
+
+	opts.LeafNode.Remotes = append(opts.LeafNode.Remotes, remote)
+
+	if stream := e.hubStream(); workQueueStreamPattern.MatchString(stream) && stream != upstreamStreamName {
+		e.logf("⚠️ Warning: hub stream %s looks like a per-org work-queue stream, which cannot be sourced; follow a limits-retention stream", stream)
+	}
+
+	return nil
+}
+
+// hubStream is the name of the hub's events stream GITHUB_EVENTS follows
+func (e *Server) hubStream() string {
+	if e.cfg.HubStream != "" {
+		return e.cfg.HubStream
+	}
+	return eventsStreamName
+}
+
+// eventsStreamSync adds the hub's events stream as a source or mirror of the
+// local stream when running as a leafnode. With EventsUpstream the local
+// stream's subjects move to the returned upstream stream, which the hub
+// sources; local events then reach GITHUB_EVENTS through the hub, so they are
+// neither stored twice nor sent back to it. On a hub, the upstream streams of
+// LeafDomains are sourced into GITHUB_EVENTS.
+func (e *Server) eventsStreamSync(config *jetstream.StreamConfig) *jetstream.StreamConfig {
+	for _, domain := range e.cfg.LeafDomains {
+		config.Sources = append(config.Sources, &jetstream.StreamSource{Name: upstreamStreamName, Domain: domain})
+	}
+	if e.cfg.LeafURL == "" {
+		return nil
+	}
+
+	var upstream *jetstream.StreamConfig
+	if e.cfg.EventsUpstream {
+		cfg := *config
+		cfg.Name = upstreamStreamName
+		cfg.Description = "GitHub events published on this leafnode, sourced by the hub"
+		cfg.Sources = nil
+		upstream = &cfg
+		config.Subjects = nil
+	}
+
+	hub := &jetstream.StreamSource{Name: e.hubStream(), Domain: e.cfg.HubDomain}
+	if e.cfg.EventsSync == SyncMirror {
+		config.Subjects = nil
+		config.Mirror = hub
+		return upstream
+	}
+	config.Sources = []*jetstream.StreamSource{hub}
+	return upstream
+}
+
+// checkHubStream rejects a hub stream with work-queue retention, which cannot
+// be sourced from: each message would go to the first leaf and be gone for
+// everyone else. A hub that cannot be reached yet is not checked.
+func (e *Server) checkHubStream(ctx context.Context, nc *nats.Conn) error {
+	if e.cfg.LeafURL == "" {
+		return nil
+	}
+
+	hub, err := jetstream.NewWithDomain(nc, e.cfg.HubDomain)
+	if err != nil {
+		return fmt.Errorf("failed to get hub JetStream context: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, hubCheckTimeout)
+	defer cancel()
+
+	stream, err := hub.Stream(ctx, e.hubStream())
+	if err != nil {
+		e.logf("⚠️ Warning: could not check hub stream %s in domain %q: %v", e.hubStream(), e.cfg.HubDomain, err)
+		return nil
+	}
+	if stream.CachedInfo().Config.Retention == jetstream.WorkQueuePolicy {
+		return fmt.Errorf("hub stream %s has work-queue retention and cannot be sourced; follow a limits-retention stream with -hub-stream", e.hubStream())
+	}
+	return nil
+}
+
+// hubCheckTimeout bounds the hub stream lookup, so that an offline leafnode
+// starts without waiting for it
+const hubCheckTimeout = 3 * time.Second
+
+// workQueueStreamPattern matches the work-queue streams older Terraform
+// configurations provisioned per org
+var workQueueStreamPattern = regexp.MustCompile(`^GITHUB_EVENTS_[A-Z0-9_]+$`)
+
+// Domain returns the JetStream domain of this server, or "" when none is set
+func (e *Server) Domain() string {
+	return e.cfg.Domain
+}
@@ -0,0 +1,188 @@
+package embeddednats_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/embeddednats"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// freePort returns a TCP port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// testOptions returns settings for a test server named name
+func testOptions(t *testing.T, name string) embeddednats.Options {
+	cfg := embeddednats.DefaultOptions()
+	cfg.Port = 0
+	cfg.HTTPPort = -1
+	cfg.StatusPort = -1
+	cfg.HealthInterval = 0
+	cfg.StoreDir = t.TempDir()
+	cfg.ServerName = name
+	cfg.Logf = func(format string, args ...interface{}) { t.Logf("["+name+"] "+format, args...) }
+	return cfg
+}
+
+// startServer starts a server stopped when the test finishes
+func startServer(t *testing.T, cfg embeddednats.Options) *embeddednats.Server {
+	t.Helper()
+	s, err := embeddednats.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// streamSubjects waits until stream holds want messages and returns its
+// message count per subject
+func streamSubjects(t *testing.T, s *embeddednats.Server, stream string, want uint64) map[string]uint64 {
+	t.Helper()
+	nc, err := s.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		str, err := js.Stream(ctx, stream)
+		if err == nil {
+			info, err := str.Info(ctx, jetstream.WithSubjectFilter(">"))
+			if err == nil && info.State.Msgs >= want {
+				return info.State.Subjects
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s did not reach %d messages: %v", stream, want, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestLeafEventsUpstream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a hub and a leafnode")
+	}
+
+	leafPort := freePort(t)
+	hubCfg := testOptions(t, "hub")
+	hubCfg.Domain = "hub"
+	hubCfg.LeafListenPort = leafPort
+	hubCfg.LeafDomains = []string{"leaf-a"}
+
+	leafCfg := testOptions(t, "leaf-a")
+	leafCfg.Domain = "leaf-a"
+	leafCfg.LeafURL = fmt.Sprintf("nats-leaf://127.0.0.1:%d", leafPort)
+	leafCfg.HubDomain = "hub"
+	leafCfg.EventsUpstream = true
+
+	// The leaf starts offline and keeps what is published on it
+	leaf := startServer(t, leafCfg)
+	nc, err := leaf.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := js.Publish(context.Background(), "github.acme.template_changed", []byte(`{"org":"acme"}`))
+	if err != nil {
+		t.Fatalf("publish while offline: %v", err)
+	}
+	if ack.Stream != "GITHUB_EVENTS_UPSTREAM" {
+		t.Errorf("leaf event stored in %s, want GITHUB_EVENTS_UPSTREAM", ack.Stream)
+	}
+
+	hub := startServer(t, hubCfg)
+	hubNC, err := hub.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hubNC.Close()
+	if err := hubNC.Publish("github.acme.workflow_status", []byte(`{"org":"acme"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both events reach the hub, and each reaches the leaf's GITHUB_EVENTS once
+	for _, s := range []struct {
+		name   string
+		server *embeddednats.Server
+	}{{"hub", hub}, {"leaf", leaf}} {
+		subjects := streamSubjects(t, s.server, "GITHUB_EVENTS", 2)
+		for _, subject := range []string{"github.acme.template_changed", "github.acme.workflow_status"} {
+			if subjects[subject] != 1 {
+				t.Errorf("%s GITHUB_EVENTS holds %d messages on %s, want 1 (%v)", s.name, subjects[subject], subject, subjects)
+			}
+		}
+	}
+}
+
+func TestLeafRejectsWorkQueueHubStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a hub and a leafnode")
+	}
+
+	spec := filepath.Join(t.TempDir(), "jetstream.yaml")
+	if err := os.WriteFile(spec, []byte(`streams:
+  - name: GITHUB_EVENTS_ACME
+    subjects: [github.acme.*]
+    retention: workqueue
+    storage: file
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	leafPort := freePort(t)
+	hubCfg := testOptions(t, "hub")
+	hubCfg.Domain = "hub"
+	hubCfg.LeafListenPort = leafPort
+	hubCfg.SpecFile = spec
+	startServer(t, hubCfg)
+
+	leafCfg := testOptions(t, "leaf")
+	leafCfg.LeafURL = fmt.Sprintf("nats-leaf://127.0.0.1:%d", leafPort)
+	leafCfg.HubDomain = "hub"
+	leafCfg.HubStream = "GITHUB_EVENTS_ACME"
+
+	// Let the leaf connect before it provisions, so it can see the hub stream
+	var leaf *embeddednats.Server
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		leaf = startServer(t, leafCfg)
+		check := leaf.Readiness().Checks[embeddednats.StageStreams]
+		if strings.Contains(check.Error, "work-queue") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("leaf following a work-queue hub stream started with streams check %+v", check)
+		}
+		leaf.Stop()
+		leafCfg.StoreDir = t.TempDir()
+	}
+}
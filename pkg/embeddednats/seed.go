@@ -0,0 +1,321 @@
+package embeddednats
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// maxSeedGap caps the pause between seed events during timing replay, so a
+// fixture recorded over hours still plays back in a demo-friendly time
+const maxSeedGap = time.Minute
+
+// SeedEvent is one canned message. Seed files are NDJSON where each line is
+// either an envelope {"subject": ..., "published": ..., "event": {...}} - the
+// format `nats-controller replay` prints - or a bare GitHub event
+// {"org": ..., "event_type": ..., "timestamp": ...} published to
+// github.<org>.<event_type>.
+type SeedEvent struct {
+	Subject   string
+	Published time.Time // zero when the line has no timestamp
+	Data      []byte
+	Source    string // file:line, for error messages
+}
+
+// seedLine is the envelope form of a seed line
+type seedLine struct {
+	Subject   string          `json:"subject"`
+	Published time.Time       `json:"published"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// seedEvent is the subset of a bare GitHub event used for routing and timing
+type seedEvent struct {
+	Org       string `json:"org"`
+	EventType string `json:"event_type"`
+	Timestamp string `json:"timestamp"`
+}
+
+// LoadSeedDir reads every *.ndjson and *.jsonl file in dir, in file name order
+func LoadSeedDir(dir string) ([]SeedEvent, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".ndjson" || ext == ".jsonl") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .ndjson or .jsonl files in %s", dir)
+	}
+
+	var events []SeedEvent
+	for _, file := range files {
+		fileEvents, err := LoadSeedFile(file)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, fileEvents...)
+	}
+
+	return events, nil
+}
+
+// LoadSeedFile reads one NDJSON seed file; blank lines and lines starting with # are skipped
+func LoadSeedFile(path string) ([]SeedEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open seed file: %w", err)
+	}
+	defer f.Close()
+
+	var events []SeedEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		source := fmt.Sprintf("%s:%d", path, n)
+		event, err := parseSeedLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		event.Source = source
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return events, nil
+}
+
+// parseSeedLine decodes an envelope or bare GitHub event line
+func parseSeedLine(line []byte) (SeedEvent, error) {
+	var envelope seedLine
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return SeedEvent{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if envelope.Subject != "" {
+		if len(envelope.Event) == 0 {
+			return SeedEvent{}, fmt.Errorf("envelope for %s has no event", envelope.Subject)
+		}
+		return SeedEvent{Subject: envelope.Subject, Published: envelope.Published, Data: envelope.Event}, nil
+	}
+
+	var bare seedEvent
+	if err := json.Unmarshal(line, &bare); err != nil {
+		return SeedEvent{}, fmt.Errorf("invalid event: %w", err)
+	}
+	if bare.Org == "" || bare.EventType == "" {
+		return SeedEvent{}, fmt.Errorf("line needs either a subject or org and event_type")
+	}
+
+	event := SeedEvent{
+		Subject: fmt.Sprintf("github.%s.%s", bare.Org, bare.EventType),
+		Data:    append([]byte(nil), line...),
+	}
+	if bare.Timestamp != "" {
+		published, err := time.Parse(time.RFC3339, bare.Timestamp)
+		if err != nil {
+			return SeedEvent{}, fmt.Errorf("invalid timestamp %q: %w", bare.Timestamp, err)
+		}
+		event.Published = published
+	}
+
+	return event, nil
+}
+
+// Seed publishes events in order. Subjects captured by a stream are published
+// through JetStream; others (e.g. system.<org>.health) as plain messages.
+// When speed > 0 the events are merged into one timeline and the gaps between
+// their timestamps are replayed, divided by speed and capped at maxSeedGap.
+func (e *Server) Seed(ctx context.Context, events []SeedEvent, speed float64) (int, error) {
+	if speed > 0 {
+		events = timeline(events)
+	}
+
+	nc, err := e.Connect()
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	var last time.Time
+	for i, event := range events {
+		if speed > 0 && !last.IsZero() && !event.Published.IsZero() {
+			if gap := min(time.Duration(float64(event.Published.Sub(last))/speed), maxSeedGap); gap > 0 {
+				select {
+				case <-ctx.Done():
+					return i, ctx.Err()
+				case <-time.After(gap):
+				}
+			}
+		}
+		if !event.Published.IsZero() {
+			last = event.Published
+		}
+
+		if err := publishSeed(ctx, nc, js, event); err != nil {
+			return i, fmt.Errorf("%s: %w", event.Source, err)
+		}
+	}
+
+	return len(events), nil
+}
+
+// timeline orders events by timestamp across files, or keeps file order when
+// some events have no timestamp
+func timeline(events []SeedEvent) []SeedEvent {
+	for _, event := range events {
+		if event.Published.IsZero() {
+			return events
+		}
+	}
+
+	sorted := make([]SeedEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Published.Before(sorted[j].Published)
+	})
+	return sorted
+}
+
+// publishSeed publishes one event, falling back to core NATS when no stream captures it
+func publishSeed(ctx context.Context, nc *nats.Conn, js jetstream.JetStream, event SeedEvent) error {
+	_, err := js.Publish(ctx, event.Subject, event.Data)
+	if errors.Is(err, jetstream.ErrNoStreamResponse) || errors.Is(err, nats.ErrNoResponders) {
+		return nc.Publish(event.Subject, event.Data)
+	}
+	return err
+}
+
+// seedOnStart publishes the configured seed directory after startup, unless
+// a persistent store already holds messages in the streams it publishes to.
+// Timing replay runs in the background until done or the server stops.
+func (e *Server) seedOnStart() {
+	if len(e.seed) == 0 {
+		e.ready.mark(StageSeed, nil, "no seed configured")
+		return
+	}
+
+	stored, err := e.seededStreams(e.ctx)
+	if err != nil {
+		e.ready.mark(StageSeed, fmt.Errorf("failed to check for seeded streams: %w", err), "")
+		e.logf("⚠️ Warning: not seeding: failed to check for seeded streams: %v", err)
+		return
+	}
+	if len(stored) > 0 {
+		detail := fmt.Sprintf("skipped, %s already hold messages", strings.Join(stored, ", "))
+		e.ready.mark(StageSeed, nil, detail)
+		e.logf("🌱 Seed %s", detail)
+		return
+	}
+
+	run := func() {
+		n, err := e.Seed(e.ctx, e.seed, e.cfg.SeedSpeed)
+		if err != nil {
+			e.ready.mark(StageSeed, fmt.Errorf("seeding stopped after %d events: %w", n, err), "")
+			e.logf("⚠️ Warning: seeding stopped after %d events: %v", n, err)
+			return
+		}
+		e.ready.mark(StageSeed, nil, fmt.Sprintf("%d events from %s", n, e.cfg.SeedDir))
+		e.logf("🌱 Seeded %d events from %s (%s)", n, e.cfg.SeedDir, seedSummary(e.seed))
+	}
+
+	if e.cfg.SeedSpeed > 0 {
+		e.ready.pending(StageSeed, fmt.Sprintf("replaying %d events at %gx speed", len(e.seed), e.cfg.SeedSpeed))
+		e.logf("🌱 Replaying %d seed events at %gx speed", len(e.seed), e.cfg.SeedSpeed)
+		go run()
+		return
+	}
+	run()
+}
+
+// seededStreams returns the streams capturing seed subjects that already hold
+// messages, as "NAME (n messages)"
+func (e *Server) seededStreams(ctx context.Context) ([]string, error) {
+	nc, err := e.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	checked := make(map[string]bool)
+	var stored []string
+	for _, event := range e.seed {
+		name, err := js.StreamNameBySubject(ctx, event.Subject)
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			continue // published as a plain message
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find stream for %s: %w", event.Subject, err)
+		}
+		if checked[name] {
+			continue
+		}
+		checked[name] = true
+
+		stream, err := js.Stream(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stream %s: %w", name, err)
+		}
+		if msgs := stream.CachedInfo().State.Msgs; msgs > 0 {
+			stored = append(stored, fmt.Sprintf("%s (%d messages)", name, msgs))
+		}
+	}
+	sort.Strings(stored)
+	return stored, nil
+}
+
+// seedSummary describes the subjects in a seed set, for logging
+func seedSummary(events []SeedEvent) string {
+	counts := make(map[string]int)
+	for _, event := range events {
+		counts[event.Subject]++
+	}
+
+	subjects := make([]string, 0, len(counts))
+	for subject := range counts {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+
+	parts := make([]string, len(subjects))
+	for i, subject := range subjects {
+		parts[i] = fmt.Sprintf("%s×%d", subject, counts[subject])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,28 @@
+package embeddednats_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joeblew999/.github/pkg/embeddednats"
+)
+
+func TestSeedOnlyIntoEmptyStreams(t *testing.T) {
+	cfg := testOptions(t, "seed")
+	cfg.SeedDir = "embeddednatstest/testdata"
+
+	// A restart with the same store must not publish the fixtures again
+	for start := 1; start <= 2; start++ {
+		s := startServer(t, cfg)
+		subjects := streamSubjects(t, s, "GITHUB_EVENTS", 3)
+		if total := subjects["github.acme.template_changed"] + subjects["github.acme.regeneration_requested"] + subjects["github.acme.workflow_status"]; total != 3 {
+			t.Errorf("start %d: GITHUB_EVENTS holds %d seed events, want 3 (%v)", start, total, subjects)
+		}
+
+		check := s.Readiness().Checks[embeddednats.StageSeed]
+		if skipped := strings.Contains(check.Detail, "skipped"); !check.Ready || skipped != (start == 2) {
+			t.Errorf("start %d: seed check %+v", start, check)
+		}
+		s.Stop()
+	}
+}
@@ -0,0 +1,522 @@
+// Package embeddednats runs an in-process NATS server with JetStream and the
+// GitHub automation streams, for local bootstrap and (through embeddednatstest)
+// integration tests.
+package embeddednats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// Server is an embedded NATS server for bootstrap/development
+type Server struct {
+	server   *server.Server
+	opts     *server.Options
+	cfg      Options
+	tempDir  string
+	creds    map[string]string // account name -> generated .creds file
+	accounts map[string]string // account name -> generated account public key
+	tls      *TLSFiles
+
+	// replicas is the default replica count for provisioned streams
+	replicas int
+	spec     *clusterSpec
+
+	// jsSpec declares the JetStream resources provisioned on start
+	jsSpec *Spec
+
+	// seed events published after start
+	seed []SeedEvent
+
+	// ctx ends background seeding and health reporting; cancelled by Stop
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// ready tracks startup for /readyz, served by status on StatusPort
+	ready     *readiness
+	status    *http.Server
+	statusURL string
+}
+
+// clusterSpec describes this server's place in a cluster started by Cluster
+type clusterSpec struct {
+	name    string
+	port    int
+	routes  []*url.URL
+	keysDir string // shared auth/TLS material for all nodes
+	size    int
+}
+
+// Options configures the embedded NATS server
+type Options struct {
+	Host       string // interface to listen on
+	Port       int    // client port, 0 for a random free port
+	HTTPPort   int    // monitoring port, 0 for a random free port, -1 to disable
+	StoreDir   string // JetStream storage; empty for a temp dir removed on Stop
+	ServerName string
+	LogLevel   string // none, info, debug or trace
+
+	// Auth generates an operator/account/user hierarchy in the store dir and
+	// runs the server in operator mode; see AccountGitHub and AccountPlaywright
+	Auth bool
+
+	// TLS generates a dev CA with server and client certificates in the store
+	// dir and serves TLS; MTLS additionally requires client certificates
+	TLS  bool
+	MTLS bool
+
+	// Domain is the JetStream domain of this server (DefaultLeafDomain in leafnode mode)
+	Domain string
+
+	// LeafURL connects to a hub (Synadia Cloud or a self-hosted core) as a
+	// leafnode, authenticating with LeafCredsFile. GITHUB_EVENTS follows the
+	// hub's HubStream (default GITHUB_EVENTS), which must not have work-queue
+	// retention, in HubDomain according to EventsSync (SyncSource or
+	// SyncMirror). With EventsUpstream, events published on the leaf are kept
+	// in GITHUB_EVENTS_UPSTREAM, also while offline, until the hub sources
+	// them; they then reach GITHUB_EVENTS from the hub. The leaf's Domain must
+	// be unique among the hub's leafnodes.
+	LeafURL        string
+	LeafCredsFile  string
+	HubDomain      string
+	HubStream      string
+	EventsSync     string
+	EventsUpstream bool
+
+	// LeafListenPort accepts leafnode connections, making this server a hub; 0 disables
+	LeafListenPort int
+
+	// LeafDomains are the JetStream domains of leafnodes running with
+	// EventsUpstream; their GITHUB_EVENTS_UPSTREAM streams are sourced into
+	// this server's GITHUB_EVENTS
+	LeafDomains []string
+
+	// ConfigFile loads a nats-server config file instead. Listen, auth and
+	// monitoring settings come from the file; JetStream is always enabled.
+	ConfigFile string
+
+	// CredsFile is the user the bootstrap connects as to provision streams
+	// when auth comes from ConfigFile
+	CredsFile string
+
+	// SpecFile is a YAML or JSON JetStream spec; empty for the built-in default
+	SpecFile string
+
+	// SeedDir holds NDJSON fixtures published after startup, unless the
+	// streams they go to already hold messages; SeedSpeed > 0 replays the
+	// gaps between their timestamps at that speed-up factor
+	SeedDir   string
+	SeedSpeed float64
+
+	// StatusPort serves readiness (/readyz), liveness (/healthz) and the
+	// current NATSHealthEvent (/health); 0 for a random free port, -1 to disable
+	StatusPort int
+
+	// Org enables NATSHealthEvent messages on system.<org>.nats_health every
+	// HealthInterval, built from the server's varz and jsz data
+	Org            string
+	HealthInterval time.Duration
+
+	// RestoreDir holds stream snapshots (see BackupStreams) restored on start,
+	// before the spec is provisioned. Streams that already exist are skipped,
+	// or deleted and restored with RestoreReplace.
+	RestoreDir     string
+	RestoreReplace bool
+
+	// Logf receives the package's progress messages; defaults to log.Printf
+	Logf func(format string, args ...interface{})
+}
+
+// DefaultOptions returns the standard development settings
+func DefaultOptions() Options {
+	return Options{
+		Host:       "127.0.0.1",
+		Port:       4222,
+		HTTPPort:   8222,
+		ServerName: "nats-bootstrap",
+		LogLevel:   "none",
+		HubDomain:  DefaultHubDomain,
+		EventsSync: SyncSource,
+		StatusPort: DefaultStatusPort,
+
+		HealthInterval: 30 * time.Second,
+	}
+}
+
+// New creates a new embedded NATS server
+func New(cfg Options) (*Server, error) {
+	return newServer(cfg, nil)
+}
+
+// newServer creates a standalone server, or a cluster node when spec is set
+func newServer(cfg Options, spec *clusterSpec) (*Server, error) {
+	if cfg.Logf == nil {
+		cfg.Logf = log.Printf
+	}
+
+	if cfg.LeafURL != "" {
+		if cfg.Domain == "" {
+			cfg.Domain = DefaultLeafDomain
+		}
+		if cfg.HubDomain == "" {
+			cfg.HubDomain = DefaultHubDomain
+		}
+		if cfg.EventsSync == "" {
+			cfg.EventsSync = SyncSource
+		}
+	}
+
+	e := &Server{cfg: cfg, replicas: 1, spec: spec}
+
+	jsSpec, err := loadSpec(cfg.SpecFile)
+	if err != nil {
+		return nil, err
+	}
+	e.jsSpec = jsSpec
+
+	if cfg.SeedDir != "" {
+		seed, err := LoadSeedDir(cfg.SeedDir)
+		if err != nil {
+			return nil, err
+		}
+		e.seed = seed
+	}
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.ready = newReadiness()
+
+	storeDir := cfg.StoreDir
+	if storeDir == "" {
+		// Create temporary directory for JetStream storage
+		tempDir, err := os.MkdirTemp("", "nats-bootstrap-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		e.tempDir = tempDir
+		storeDir = tempDir
+	} else if err := os.MkdirAll(storeDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	opts, err := e.serverOptions(storeDir, spec)
+	if err != nil {
+		e.cleanup()
+		return nil, err
+	}
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		e.cleanup()
+		return nil, fmt.Errorf("failed to create NATS server: %w", err)
+	}
+
+	if !opts.NoLog {
+		s.ConfigureLogger()
+	}
+
+	e.server = s
+	e.opts = opts
+	return e, nil
+}
+
+// serverOptions builds the nats-server options from the config file or flags
+func (e *Server) serverOptions(storeDir string, spec *clusterSpec) (*server.Options, error) {
+	cfg := e.cfg
+
+	keysDir := storeDir
+	if spec != nil {
+		if cfg.ConfigFile != "" {
+			return nil, fmt.Errorf("cluster mode and a server config file are mutually exclusive")
+		}
+		keysDir = spec.keysDir
+	}
+
+	if cfg.ConfigFile != "" && cfg.Auth {
+		return nil, fmt.Errorf("generated auth and a server config file are mutually exclusive")
+	}
+	if cfg.ConfigFile != "" && (cfg.TLS || cfg.MTLS) {
+		return nil, fmt.Errorf("generated TLS and a server config file are mutually exclusive")
+	}
+
+	opts := &server.Options{
+		Host:     cfg.Host,
+		Port:     listenPort(cfg.Port),
+		HTTPHost: cfg.Host,
+		HTTPPort: listenPort(cfg.HTTPPort),
+	}
+	if cfg.HTTPPort < 0 {
+		opts.HTTPPort = 0 // monitoring disabled
+	}
+
+	if cfg.ConfigFile != "" {
+		fileOpts, err := server.ProcessConfigFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load server config %s: %w", cfg.ConfigFile, err)
+		}
+		opts = fileOpts
+	}
+
+	// JetStream configuration
+	opts.JetStream = true
+	if cfg.Domain != "" {
+		opts.JetStreamDomain = cfg.Domain
+	}
+	if opts.StoreDir == "" {
+		opts.StoreDir = filepath.Join(storeDir, "jetstream")
+	}
+
+	// Cluster name for development
+	if opts.ServerName == "" {
+		opts.ServerName = cfg.ServerName
+	}
+
+	// Logging
+	opts.Logtime = true
+	opts.NoLog = cfg.LogLevel == "none"
+
+	switch cfg.LogLevel {
+	case "none", "info":
+	case "debug":
+		opts.Debug = true
+	case "trace":
+		opts.Debug = true
+		opts.Trace = true
+	default:
+		return nil, fmt.Errorf("unknown log level %q (want none, info, debug or trace)", cfg.LogLevel)
+	}
+
+	if spec != nil {
+		opts.Cluster = server.ClusterOpts{
+			Name: spec.name,
+			Host: cfg.Host,
+			Port: spec.port,
+		}
+		opts.Routes = spec.routes
+		e.replicas = min(spec.size, 3)
+	}
+
+	if cfg.Auth {
+		if err := e.setupAuth(opts, keysDir); err != nil {
+			return nil, fmt.Errorf("failed to generate auth: %w", err)
+		}
+	}
+
+	if cfg.TLS || cfg.MTLS {
+		if err := e.setupTLS(opts, keysDir); err != nil {
+			return nil, fmt.Errorf("failed to generate TLS certificates: %w", err)
+		}
+	}
+
+	if cfg.LeafListenPort != 0 {
+		opts.LeafNode.Host = cfg.Host
+		opts.LeafNode.Port = cfg.LeafListenPort
+	}
+
+	if cfg.LeafURL != "" {
+		if err := e.setupLeaf(opts); err != nil {
+			return nil, fmt.Errorf("failed to configure leafnode: %w", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// loadSpec loads a spec file, or the built-in default when path is empty
+func loadSpec(path string) (*Spec, error) {
+	if path == "" {
+		return DefaultSpec()
+	}
+	return LoadSpec(path)
+}
+
+// listenPort maps our "0 means random" convention onto nats-server's, where
+// 0 selects the default port and -1 a random one
+func listenPort(port int) int {
+	if port == 0 {
+		return server.RANDOM_PORT
+	}
+	return port
+}
+
+// logf writes a progress message through the configured logger
+func (e *Server) logf(format string, args ...interface{}) {
+	e.cfg.Logf(format, args...)
+}
+
+// Start starts the embedded NATS server and provisions the GitHub streams.
+// Connectivity and stream problems are logged as warnings.
+func (e *Server) Start() error {
+	if err := e.startStatusServer(); err != nil {
+		return err
+	}
+
+	if err := e.startServer(); err != nil {
+		e.stopStatusServer()
+		return err
+	}
+	e.ready.mark(StageServer, nil, e.GetConnectionURL())
+
+	// Test basic connectivity
+	if err := e.testConnectivity(); err != nil {
+		e.logf("⚠️ Warning: connectivity test failed: %v", err)
+	} else {
+		e.logf("✅ Connectivity test passed")
+	}
+
+	if err := e.restoreOnStart(); err != nil {
+		e.Stop()
+		return fmt.Errorf("failed to restore %s: %w", e.cfg.RestoreDir, err)
+	}
+
+	// Create basic JetStream configuration for GitHub events
+	err := e.SetupGitHubStreams()
+	e.ready.mark(StageStreams, err, "")
+	if err != nil {
+		e.logf("⚠️ Warning: failed to setup GitHub streams: %v", err)
+	} else {
+		e.logf("✅ GitHub event streams configured")
+	}
+
+	e.seedOnStart()
+	go e.publishHealth(e.ctx)
+
+	return nil
+}
+
+// startServer starts the server and waits until it accepts connections
+func (e *Server) startServer() error {
+	e.logf("🚀 Starting embedded NATS server v%s", server.VERSION)
+	e.logf("   Server Name: %s", e.opts.ServerName)
+	e.logf("   JetStream Store: %s", e.opts.StoreDir)
+	if e.cfg.ConfigFile != "" {
+		e.logf("   Config File: %s", e.cfg.ConfigFile)
+	}
+	if e.cfg.Auth {
+		e.logf("   Auth: operator mode (accounts %s, %s)", AccountGitHub, AccountPlaywright)
+	}
+	if e.tls != nil {
+		mode := "TLS"
+		if e.cfg.MTLS {
+			mode = "mutual TLS"
+		}
+		e.logf("   TLS: %s (CA %s)", mode, e.tls.CAFile)
+	}
+	if e.cfg.LeafURL != "" {
+		e.logf("   Leafnode: hub %s, JetStream domain %q, GITHUB_EVENTS %ss %s in domain %q", e.cfg.LeafURL, e.cfg.Domain, e.cfg.EventsSync, e.hubStream(), e.cfg.HubDomain)
+		if e.cfg.EventsUpstream {
+			e.logf("   Upstream: local events kept in %s for the hub to source", upstreamStreamName)
+		}
+	}
+	if len(e.cfg.LeafDomains) > 0 {
+		e.logf("   Leafnode upstream: GITHUB_EVENTS sources %s from domains %s", upstreamStreamName, strings.Join(e.cfg.LeafDomains, ", "))
+	}
+
+	// Start the server
+	go e.server.Start()
+
+	// Wait for server to be ready
+	if !e.server.ReadyForConnections(10 * time.Second) {
+		return fmt.Errorf("NATS server failed to start within 10 seconds")
+	}
+
+	e.logf("✅ NATS server started successfully")
+	e.logf("   Client: %s", e.GetConnectionURL())
+	if monitorURL := e.GetMonitorURL(); monitorURL != "" {
+		e.logf("   HTTP Monitor: %s", monitorURL)
+	}
+
+	return nil
+}
+
+// Stop stops the embedded NATS server
+func (e *Server) Stop() {
+	e.logf("🛑 Stopping embedded NATS server...")
+	e.cancel()
+	e.stopStatusServer()
+
+	if e.server != nil {
+		e.server.Shutdown()
+		e.server.WaitForShutdown()
+	}
+
+	e.cleanup()
+
+	e.logf("✅ NATS server stopped")
+}
+
+// cleanup removes the temporary store directory; persistent store dirs are kept
+func (e *Server) cleanup() {
+	if e.tempDir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(e.tempDir); err != nil {
+		e.logf("Warning: failed to cleanup temp directory: %v", err)
+	} else {
+		e.logf("✅ Temporary files cleaned up")
+	}
+}
+
+// testConnectivity tests basic NATS connectivity
+func (e *Server) testConnectivity() error {
+	nc, err := e.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer nc.Close()
+
+	// Test basic pub/sub
+	if err := nc.Publish("test.bootstrap", []byte("Bootstrap test message")); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+
+	return nil
+}
+
+// Connect opens a client connection to the server, as the GitHub account
+// user when auth is enabled and trusting the dev CA when TLS is enabled
+func (e *Server) Connect(opts ...nats.Option) (*nats.Conn, error) {
+	return e.connectTo(e.GetConnectionURL(), opts...)
+}
+
+// connectTo connects to urls with this server's credentials and TLS settings
+func (e *Server) connectTo(urls string, opts ...nats.Option) (*nats.Conn, error) {
+	opts = append(e.tlsOptions(), opts...)
+
+	credsFile := e.cfg.CredsFile
+	if generated := e.CredsFile(AccountGitHub); generated != "" {
+		credsFile = generated
+	}
+	if credsFile != "" {
+		opts = append([]nats.Option{nats.UserCredentials(credsFile)}, opts...)
+	}
+	return nats.Connect(urls, opts...)
+}
+
+// GetConnectionURL returns the NATS connection URL of the bound listener
+func (e *Server) GetConnectionURL() string {
+	return e.server.ClientURL()
+}
+
+// GetMonitorURL returns the HTTP monitor URL of the bound listener, or "" when disabled
+func (e *Server) GetMonitorURL() string {
+	addr := e.server.MonitorAddr()
+	if addr == nil {
+		return ""
+	}
+	return fmt.Sprintf("http://%s", addr)
+}
+
+// NATSServer returns the underlying nats-server instance
+func (e *Server) NATSServer() *server.Server {
+	return e.server
+}
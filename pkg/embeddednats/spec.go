@@ -0,0 +1,377 @@
+package embeddednats
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultSpec provisions GITHUB_EVENTS and WORKFLOW_COORDINATION
+//
+//go:embed jetstream.yaml
+var defaultSpec []byte
+
+// Spec declares the JetStream resources to provision. Field names and value
+// formats ("7d", "1GB", "workqueue") follow the Terraform nats_stream and
+// nats_consumer resources, so definitions can move between the two unchanged.
+type Spec struct {
+	Streams      []StreamSpec      `yaml:"streams"`
+	KeyValue     []KeyValueSpec    `yaml:"kv_buckets"`
+	ObjectStores []ObjectStoreSpec `yaml:"object_stores"`
+}
+
+// StreamSpec declares a stream and its consumers
+type StreamSpec struct {
+	Name            string         `yaml:"name"`
+	Description     string         `yaml:"description"`
+	Subjects        []string       `yaml:"subjects"`
+	Retention       string         `yaml:"retention"` // limits, interest or workqueue
+	MaxAge          Duration       `yaml:"max_age"`
+	MaxMsgs         int64          `yaml:"max_msgs"`
+	MaxBytes        ByteSize       `yaml:"max_bytes"`
+	Storage         string         `yaml:"storage"`  // file or memory
+	Replicas        int            `yaml:"replicas"` // 0 for the server default (1, or 3 in a cluster)
+	Discard         string         `yaml:"discard"`  // old or new
+	DuplicateWindow Duration       `yaml:"duplicate_window"`
+	Consumers       []ConsumerSpec `yaml:"consumers"`
+}
+
+// ConsumerSpec declares a consumer on its parent stream
+type ConsumerSpec struct {
+	Name          string   `yaml:"name"`
+	Description   string   `yaml:"description"`
+	Durable       bool     `yaml:"durable"`
+	FilterSubject string   `yaml:"filter_subject"`
+	AckPolicy     string   `yaml:"ack_policy"` // explicit, all or none
+	AckWait       Duration `yaml:"ack_wait"`
+	MaxDeliver    int      `yaml:"max_deliver"`
+	DeliverPolicy string   `yaml:"deliver_policy"` // all, last, new or last_per_subject
+	RateLimit     uint64   `yaml:"rate_limit"`     // bits per second
+}
+
+// KeyValueSpec declares a KV bucket
+type KeyValueSpec struct {
+	Bucket      string   `yaml:"bucket"`
+	Description string   `yaml:"description"`
+	History     uint8    `yaml:"history"`
+	TTL         Duration `yaml:"ttl"`
+	MaxBytes    ByteSize `yaml:"max_bytes"`
+	Storage     string   `yaml:"storage"`
+	Replicas    int      `yaml:"replicas"`
+}
+
+// ObjectStoreSpec declares an object store bucket
+type ObjectStoreSpec struct {
+	Bucket      string   `yaml:"bucket"`
+	Description string   `yaml:"description"`
+	TTL         Duration `yaml:"ttl"`
+	MaxBytes    ByteSize `yaml:"max_bytes"`
+	Storage     string   `yaml:"storage"`
+	Replicas    int      `yaml:"replicas"`
+}
+
+// Duration is a time.Duration that also accepts a day suffix, e.g. "7d"
+type Duration time.Duration
+
+// UnmarshalYAML parses "30s", "24h" or "7d"
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	value := strings.TrimSpace(node.Value)
+	if value == "" || value == "0" {
+		*d = 0
+		return nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*d = Duration(time.Duration(n * float64(24*time.Hour)))
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// ByteSize is a byte count that also accepts KB, MB, GB and TB suffixes (1024-based)
+type ByteSize int64
+
+// byteUnits are the recognised size suffixes, longest first
+var byteUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// UnmarshalYAML parses "1048576", "100MB" or "1GB"
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	value := strings.ToUpper(strings.TrimSpace(node.Value))
+
+	factor := int64(1)
+	for _, unit := range byteUnits {
+		if trimmed, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, factor = strings.TrimSpace(trimmed), unit.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid size %q", node.Value)
+	}
+	*b = ByteSize(n * factor)
+	return nil
+}
+
+// DefaultSpec returns the built-in spec used when no spec file is given
+func DefaultSpec() (*Spec, error) {
+	return ParseSpec(defaultSpec)
+}
+
+// LoadSpec reads a YAML or JSON spec file
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec: %w", err)
+	}
+
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// ParseSpec parses and validates a YAML or JSON spec
+func ParseSpec(data []byte) (*Spec, error) {
+	var spec Spec
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, err
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Validate checks names and enum values by converting every resource
+func (s *Spec) Validate() error {
+	seen := make(map[string]bool)
+	for _, stream := range s.Streams {
+		if seen["stream "+stream.Name] {
+			return fmt.Errorf("stream %s declared twice", stream.Name)
+		}
+		seen["stream "+stream.Name] = true
+
+		if _, err := stream.config(1); err != nil {
+			return err
+		}
+		for _, consumer := range stream.Consumers {
+			if _, err := consumer.config(); err != nil {
+				return fmt.Errorf("stream %s: %w", stream.Name, err)
+			}
+		}
+	}
+
+	for _, kv := range s.KeyValue {
+		if _, err := kv.config(1); err != nil {
+			return err
+		}
+	}
+
+	for _, store := range s.ObjectStores {
+		if _, err := store.config(1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// config converts the spec into a jetstream stream config
+func (s StreamSpec) config(defaultReplicas int) (jetstream.StreamConfig, error) {
+	cfg := jetstream.StreamConfig{
+		Name:        s.Name,
+		Description: s.Description,
+		Subjects:    s.Subjects,
+		MaxAge:      time.Duration(s.MaxAge),
+		MaxMsgs:     s.MaxMsgs,
+		MaxBytes:    int64(s.MaxBytes),
+		Replicas:    replicasOr(s.Replicas, defaultReplicas),
+		Duplicates:  time.Duration(s.DuplicateWindow),
+	}
+	if s.Name == "" {
+		return cfg, fmt.Errorf("stream without a name")
+	}
+	if len(s.Subjects) == 0 {
+		return cfg, fmt.Errorf("stream %s: no subjects", s.Name)
+	}
+	if cfg.MaxMsgs == 0 {
+		cfg.MaxMsgs = -1
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = -1
+	}
+
+	switch s.Retention {
+	case "", "limits":
+		cfg.Retention = jetstream.LimitsPolicy
+	case "interest":
+		cfg.Retention = jetstream.InterestPolicy
+	case "workqueue":
+		cfg.Retention = jetstream.WorkQueuePolicy
+	default:
+		return cfg, fmt.Errorf("stream %s: unknown retention %q", s.Name, s.Retention)
+	}
+
+	switch s.Discard {
+	case "", "old":
+		cfg.Discard = jetstream.DiscardOld
+	case "new":
+		cfg.Discard = jetstream.DiscardNew
+	default:
+		return cfg, fmt.Errorf("stream %s: unknown discard policy %q", s.Name, s.Discard)
+	}
+
+	storage, err := storageType(s.Storage)
+	if err != nil {
+		return cfg, fmt.Errorf("stream %s: %w", s.Name, err)
+	}
+	cfg.Storage = storage
+
+	return cfg, nil
+}
+
+// config converts the spec into a jetstream consumer config
+func (c ConsumerSpec) config() (jetstream.ConsumerConfig, error) {
+	cfg := jetstream.ConsumerConfig{
+		Name:          c.Name,
+		Description:   c.Description,
+		FilterSubject: c.FilterSubject,
+		AckWait:       time.Duration(c.AckWait),
+		MaxDeliver:    c.MaxDeliver,
+		RateLimit:     c.RateLimit,
+	}
+	if c.Name == "" {
+		return cfg, fmt.Errorf("consumer without a name")
+	}
+	if c.Durable {
+		cfg.Durable = c.Name
+	}
+
+	switch c.AckPolicy {
+	case "", "explicit":
+		cfg.AckPolicy = jetstream.AckExplicitPolicy
+	case "all":
+		cfg.AckPolicy = jetstream.AckAllPolicy
+	case "none":
+		cfg.AckPolicy = jetstream.AckNonePolicy
+	default:
+		return cfg, fmt.Errorf("consumer %s: unknown ack policy %q", c.Name, c.AckPolicy)
+	}
+
+	switch c.DeliverPolicy {
+	case "", "all":
+		cfg.DeliverPolicy = jetstream.DeliverAllPolicy
+	case "last":
+		cfg.DeliverPolicy = jetstream.DeliverLastPolicy
+	case "new":
+		cfg.DeliverPolicy = jetstream.DeliverNewPolicy
+	case "last_per_subject":
+		cfg.DeliverPolicy = jetstream.DeliverLastPerSubjectPolicy
+	default:
+		return cfg, fmt.Errorf("consumer %s: unknown deliver policy %q", c.Name, c.DeliverPolicy)
+	}
+
+	return cfg, nil
+}
+
+// config converts the spec into a jetstream KV config
+func (k KeyValueSpec) config(defaultReplicas int) (jetstream.KeyValueConfig, error) {
+	cfg := jetstream.KeyValueConfig{
+		Bucket:      k.Bucket,
+		Description: k.Description,
+		History:     k.History,
+		TTL:         time.Duration(k.TTL),
+		MaxBytes:    int64(k.MaxBytes),
+		Replicas:    replicasOr(k.Replicas, defaultReplicas),
+	}
+	if k.Bucket == "" {
+		return cfg, fmt.Errorf("kv bucket without a name")
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = -1
+	}
+
+	storage, err := storageType(k.Storage)
+	if err != nil {
+		return cfg, fmt.Errorf("kv bucket %s: %w", k.Bucket, err)
+	}
+	cfg.Storage = storage
+
+	return cfg, nil
+}
+
+// config converts the spec into a jetstream object store config
+func (o ObjectStoreSpec) config(defaultReplicas int) (jetstream.ObjectStoreConfig, error) {
+	cfg := jetstream.ObjectStoreConfig{
+		Bucket:      o.Bucket,
+		Description: o.Description,
+		TTL:         time.Duration(o.TTL),
+		MaxBytes:    int64(o.MaxBytes),
+		Replicas:    replicasOr(o.Replicas, defaultReplicas),
+	}
+	if o.Bucket == "" {
+		return cfg, fmt.Errorf("object store without a name")
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = -1
+	}
+
+	storage, err := storageType(o.Storage)
+	if err != nil {
+		return cfg, fmt.Errorf("object store %s: %w", o.Bucket, err)
+	}
+	cfg.Storage = storage
+
+	return cfg, nil
+}
+
+// storageType parses a storage name, defaulting to file
+func storageType(name string) (jetstream.StorageType, error) {
+	switch name {
+	case "", "file":
+		return jetstream.FileStorage, nil
+	case "memory":
+		return jetstream.MemoryStorage, nil
+	default:
+		return 0, fmt.Errorf("unknown storage %q", name)
+	}
+}
+
+// replicasOr returns replicas, or the server default when unset
+func replicasOr(replicas, defaultReplicas int) int {
+	if replicas > 0 {
+		return replicas
+	}
+	return defaultReplicas
+}
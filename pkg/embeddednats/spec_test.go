@@ -0,0 +1,46 @@
+package embeddednats_test
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/joeblew999/.github/pkg/embeddednats"
+)
+
+func TestDefaultSpec(t *testing.T) {
+	spec, err := embeddednats.DefaultSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stream := range spec.Streams {
+		if stream.Name != "GITHUB_EVENTS" {
+			continue
+		}
+		// The controller, replay and sourcing leafnodes need events kept after delivery
+		if stream.Retention != "" && stream.Retention != "limits" {
+			t.Errorf("GITHUB_EVENTS retention = %q, want limits", stream.Retention)
+		}
+		return
+	}
+	t.Error("default spec does not declare GITHUB_EVENTS")
+}
+
+// TestTerraformUsesSpec keeps Terraform from drifting back to its own
+// stream and consumer definitions
+func TestTerraformUsesSpec(t *testing.T) {
+	tf, err := os.ReadFile("../../terraform/nats-github-infrastructure.tf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !regexp.MustCompile(`yamldecode\(file\("\$\{path\.module\}/\.\./pkg/embeddednats/jetstream\.yaml"\)\)`).Match(tf) {
+		t.Error("Terraform does not read pkg/embeddednats/jetstream.yaml")
+	}
+	for _, m := range regexp.MustCompile(`resource "(nats_stream|nats_consumer)" "(\w+)"`).FindAllSubmatch(tf, -1) {
+		if string(m[2]) != "jetstream" {
+			t.Errorf("Terraform declares %s.%s outside the spec", m[1], m[2])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package embeddednats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// eventsStreamName is the stream that follows the hub in leafnode mode
+const eventsStreamName = "GITHUB_EVENTS"
+
+// provisionTimeout bounds a full spec provisioning pass
+const provisionTimeout = 30 * time.Second
+
+// SetupGitHubStreams provisions the configured JetStream spec
+func (e *Server) SetupGitHubStreams() error {
+	ctx, cancel := context.WithTimeout(context.Background(), provisionTimeout)
+	defer cancel()
+
+	return e.Provision(ctx, e.jsSpec)
+}
+
+// Provision creates or updates every stream, consumer, KV bucket and object
+// store in spec. Resources not in the spec are left alone.
+func (e *Server) Provision(ctx context.Context, spec *Spec) error {
+	nc, err := e.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	for _, streamSpec := range spec.Streams {
+		cfg, err := streamSpec.config(e.replicas)
+		if err != nil {
+			return err
+		}
+		var upstream *jetstream.StreamConfig
+		if cfg.Name == eventsStreamName {
+			if err := e.checkHubStream(ctx, nc); err != nil {
+				return err
+			}
+			upstream = e.eventsStreamSync(&cfg)
+		}
+
+		if _, err := js.CreateOrUpdateStream(ctx, cfg); err != nil {
+			return fmt.Errorf("failed to provision stream %s: %w", cfg.Name, err)
+		}
+		// Created after GITHUB_EVENTS has given up the subjects it takes over
+		if upstream != nil {
+			if _, err := js.CreateOrUpdateStream(ctx, *upstream); err != nil {
+				return fmt.Errorf("failed to provision stream %s: %w", upstream.Name, err)
+			}
+		}
+
+		for _, consumerSpec := range streamSpec.Consumers {
+			consumerCfg, err := consumerSpec.config()
+			if err != nil {
+				return err
+			}
+			if _, err := js.CreateOrUpdateConsumer(ctx, cfg.Name, consumerCfg); err != nil {
+				return fmt.Errorf("failed to provision consumer %s on %s: %w", consumerCfg.Name, cfg.Name, err)
+			}
+		}
+	}
+
+	for _, kvSpec := range spec.KeyValue {
+		cfg, err := kvSpec.config(e.replicas)
+		if err != nil {
+			return err
+		}
+		if _, err := js.CreateOrUpdateKeyValue(ctx, cfg); err != nil {
+			return fmt.Errorf("failed to provision kv bucket %s: %w", cfg.Bucket, err)
+		}
+	}
+
+	for _, storeSpec := range spec.ObjectStores {
+		cfg, err := storeSpec.config(e.replicas)
+		if err != nil {
+			return err
+		}
+		if _, err := js.CreateOrUpdateObjectStore(ctx, cfg); err != nil {
+			return fmt.Errorf("failed to provision object store %s: %w", cfg.Bucket, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package embeddednats
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// tlsDirName is the directory under the store dir holding the dev CA and certs
+const tlsDirName = "tls"
+
+// Validity of generated certificates; expired ones are regenerated on start
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+)
+
+// TLSFiles are the PEM files generated for TLS mode
+type TLSFiles struct {
+	CAFile         string
+	ServerCertFile string
+	ServerKeyFile  string
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// setupTLS generates a dev CA with server and client certificates under
+// <store>/tls (reusing them while valid) and enables TLS on the server.
+// With mTLS, clients must present a certificate signed by the same CA.
+func (e *Server) setupTLS(opts *server.Options, storeDir string) error {
+	dir := filepath.Join(storeDir, tlsDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create TLS directory: %w", err)
+	}
+
+	files := &TLSFiles{
+		CAFile:         filepath.Join(dir, "ca.pem"),
+		ServerCertFile: filepath.Join(dir, "server.pem"),
+		ServerKeyFile:  filepath.Join(dir, "server-key.pem"),
+		ClientCertFile: filepath.Join(dir, "client.pem"),
+		ClientKeyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+
+	ca, caKey, err := loadOrCreateCA(files.CAFile, filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		return err
+	}
+
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if opts.Host != "" && opts.Host != "0.0.0.0" && opts.Host != "::" {
+		hosts = append(hosts, opts.Host)
+	}
+	if err := ensureCert(files.ServerCertFile, files.ServerKeyFile, ca, caKey, e.cfg.ServerName, hosts, x509.ExtKeyUsageServerAuth); err != nil {
+		return err
+	}
+	if err := ensureCert(files.ClientCertFile, files.ClientKeyFile, ca, caKey, "nats-bootstrap-client", nil, x509.ExtKeyUsageClientAuth); err != nil {
+		return err
+	}
+
+	tlsConfig, err := server.GenTLSConfig(&server.TLSConfigOpts{
+		CertFile: files.ServerCertFile,
+		KeyFile:  files.ServerKeyFile,
+		CaFile:   files.CAFile,
+		Verify:   e.cfg.MTLS,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build TLS config: %w", err)
+	}
+
+	opts.TLS = true
+	opts.TLSVerify = e.cfg.MTLS
+	opts.TLSConfig = tlsConfig
+	opts.TLSTimeout = 2
+
+	e.tls = files
+	return nil
+}
+
+// loadOrCreateCA reads the dev CA, generating a new one if missing or expired
+func loadOrCreateCA(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if err == nil && ok && time.Now().Before(cert.NotAfter) {
+			return cert, key, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "nats-bootstrap dev CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	if err := writePEM(certFile, keyFile, der, key); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	return cert, key, nil
+}
+
+// ensureCert keeps an existing certificate if it was issued by ca and is still
+// valid, otherwise issues a new one
+func ensureCert(certFile, keyFile string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, name string, hosts []string, usage x509.ExtKeyUsage) error {
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		cert, err := x509.ParseCertificate(pair.Certificate[0])
+		if err == nil && time.Now().Before(cert.NotAfter) && cert.CheckSignatureFrom(ca) == nil && coversHosts(cert, hosts) {
+			return nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key for %s: %w", name, err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate for %s: %w", name, err)
+	}
+
+	return writePEM(certFile, keyFile, der, key)
+}
+
+// coversHosts reports whether a certificate is valid for every host
+func coversHosts(cert *x509.Certificate, hosts []string) bool {
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// writePEM writes a certificate and its private key as PEM files
+func writePEM(certFile, keyFile string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", certFile, err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", keyFile, err)
+	}
+
+	return nil
+}
+
+// serialNumber returns a random certificate serial number
+func serialNumber() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return n
+}
+
+// tlsOptions returns the client options for connecting to a TLS-enabled server
+func (e *Server) tlsOptions() []nats.Option {
+	if e.tls == nil {
+		return nil
+	}
+
+	opts := []nats.Option{nats.RootCAs(e.tls.CAFile)}
+	if e.cfg.MTLS {
+		opts = append(opts, nats.ClientCert(e.tls.ClientCertFile, e.tls.ClientKeyFile))
+	}
+	return opts
+}
+
+// TLSFiles returns the generated CA and certificates, or nil when TLS is off
+func (e *Server) TLSFiles() *TLSFiles {
+	return e.tls
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/joeblew999/.github/pkg/dryrun"
 	"github.com/nats-io/nats.go"
 	// This would be generated by bee from our protobuf schema
 	// eventsv1 "github.com/joeblew999/.github/pkg/events/v1"
@@ -20,6 +21,7 @@ type TemplateChangedHandler struct {
 	terraformBinary string
 	githubOrg       string
 	region          string
+	plan            *dryrun.Plan
 }
 
 // NewTemplateChangedHandler creates a new handler instance
@@ -32,6 +34,12 @@ func NewTemplateChangedHandler(nc *nats.Conn, terraformBinary, githubOrg, region
 	}
 }
 
+// SetPlan switches the handler to dry-run mode: publishes and Terraform runs
+// are recorded in plan instead of executed. A nil plan restores live mode.
+func (h *TemplateChangedHandler) SetPlan(plan *dryrun.Plan) {
+	h.plan = plan
+}
+
 // Handle processes template change events with type safety
 // In a real bee implementation, this signature would be generated from protobuf
 func (h *TemplateChangedHandler) Handle(ctx context.Context, event interface{}) error {
@@ -39,11 +47,23 @@ func (h *TemplateChangedHandler) Handle(ctx context.Context, event interface{})
 	log.Printf("🔄 Processing template change event for org: %s", h.githubOrg)
 
 	// Extract event data (this would be type-safe with bee)
-	eventData := event.(map[string]interface{})
+	eventData, ok := event.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid template change event: %T", event)
+	}
 
-	impactLevel := eventData["impact_level"].(string)
-	changedFiles := eventData["changed_files"].([]string)
-	commitSha := eventData["commit_sha"].(string)
+	impactLevel, ok := eventData["impact_level"].(string)
+	if !ok {
+		return fmt.Errorf("invalid template change event: no impact_level")
+	}
+	changedFiles, ok := stringList(eventData["changed_files"])
+	if !ok {
+		return fmt.Errorf("invalid template change event: no changed_files")
+	}
+	commitSha, ok := eventData["commit_sha"].(string)
+	if !ok {
+		return fmt.Errorf("invalid template change event: no commit_sha")
+	}
 
 	log.Printf("   Impact Level: %s", impactLevel)
 	log.Printf("   Changed Files: %v", changedFiles)
@@ -132,21 +152,33 @@ func (h *TemplateChangedHandler) triggerTerraformScaling(ctx context.Context, im
 	}
 
 	// Build Terraform command
-	cmd := exec.CommandContext(ctx, h.terraformBinary, "apply", "-auto-approve",
+	args := []string{"apply", "-auto-approve",
 		fmt.Sprintf("-var=github_org=%s", h.githubOrg),
 		fmt.Sprintf("-var=region=%s", h.region),
 		fmt.Sprintf("-var=load_factor=%s", loadFactor),
 		terraformConfig,
-	)
-
-	// Execute Terraform
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("❌ Terraform failed: %s", string(output))
-		return fmt.Errorf("terraform apply failed: %w", err)
 	}
 
-	log.Printf("✅ Terraform scaling completed: %s", string(output))
+	if h.plan.Enabled() {
+		h.plan.Record(dryrun.Action{
+			Kind:    dryrun.KindTerraformExec,
+			Org:     h.githubOrg,
+			Target:  h.terraformBinary,
+			Summary: fmt.Sprintf("%s %s", h.terraformBinary, strings.Join(args, " ")),
+			Payload: map[string]interface{}{"args": args},
+		})
+		log.Printf("📝 [dry-run] Terraform apply recorded")
+	} else {
+		// Execute Terraform
+		cmd := exec.CommandContext(ctx, h.terraformBinary, args...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			log.Printf("❌ Terraform failed: %s", string(output))
+			return fmt.Errorf("terraform apply failed: %w", err)
+		}
+
+		log.Printf("✅ Terraform scaling completed: %s", string(output))
+	}
 
 	// Publish Terraform operation result
 	terraformEvent := map[string]interface{}{
@@ -183,6 +215,11 @@ func (h *TemplateChangedHandler) publishEvent(subject string, event interface{})
 
 	fullSubject := fmt.Sprintf("%s.%s", subject, h.githubOrg)
 
+	if h.plan.Enabled() {
+		recordPublish(h.plan, h.githubOrg, fullSubject, event)
+		return nil
+	}
+
 	if err := h.nc.Publish(fullSubject, []byte(data)); err != nil {
 		return fmt.Errorf("failed to publish to %s: %w", fullSubject, err)
 	}
@@ -191,10 +228,29 @@ func (h *TemplateChangedHandler) publishEvent(subject string, event interface{})
 	return nil
 }
 
+// recordPublish adds a planned NATS publish to a dry-run plan
+func recordPublish(plan *dryrun.Plan, org, subject string, event interface{}) {
+	plan.Record(dryrun.Action{
+		Kind:    dryrun.KindNATSPublish,
+		Org:     org,
+		Target:  subject,
+		Summary: fmt.Sprintf("publish to %s", subject),
+		Payload: event,
+	})
+	log.Printf("📝 [dry-run] Publish to %s recorded", subject)
+}
+
 // SystemHealthHandler demonstrates health monitoring with bee
 type SystemHealthHandler struct {
 	nc        *nats.Conn
 	githubOrg string
+	plan      *dryrun.Plan
+}
+
+// SetPlan switches the handler to dry-run mode: publishes are recorded in
+// plan instead of executed. A nil plan restores live mode.
+func (h *SystemHealthHandler) SetPlan(plan *dryrun.Plan) {
+	h.plan = plan
 }
 
 // Handle processes system health events
@@ -202,15 +258,34 @@ func (h *SystemHealthHandler) Handle(ctx context.Context, event interface{}) err
 	log.Printf("💓 Processing system health event for org: %s", h.githubOrg)
 
 	// In real bee implementation, this would be type-safe
-	healthData := event.(map[string]interface{})
+	healthData, ok := event.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid system health event: %T", event)
+	}
 
-	status := healthData["status"].(string)
-	eventStats := healthData["event_stats"].(map[string]interface{})
-	infrastructure := healthData["infrastructure"].(map[string]interface{})
+	status, ok := healthData["status"].(string)
+	if !ok {
+		return fmt.Errorf("invalid system health event: no status")
+	}
+	eventStats, ok := healthData["event_stats"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid system health event: no event_stats")
+	}
+	infrastructure, ok := healthData["infrastructure"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid system health event: no infrastructure")
+	}
 
 	// Check for concerning metrics
-	eventsInQueue := eventStats["events_in_queue"].(int64)
-	cpuUsage := infrastructure["cpu_usage_percent"].(float64)
+	queued, ok := number(eventStats["events_in_queue"])
+	if !ok {
+		return fmt.Errorf("invalid system health event: no events_in_queue")
+	}
+	eventsInQueue := int64(queued)
+	cpuUsage, ok := number(infrastructure["cpu_usage_percent"])
+	if !ok {
+		return fmt.Errorf("invalid system health event: no cpu_usage_percent")
+	}
 
 	log.Printf("   System Status: %s", status)
 	log.Printf("   Events in Queue: %d", eventsInQueue)
@@ -244,6 +319,11 @@ func (h *SystemHealthHandler) publishEvent(subject string, event interface{}) er
 	data := fmt.Sprintf("%+v", event)
 	fullSubject := fmt.Sprintf("%s.%s", subject, h.githubOrg)
 
+	if h.plan.Enabled() {
+		recordPublish(h.plan, h.githubOrg, fullSubject, event)
+		return nil
+	}
+
 	if err := h.nc.Publish(fullSubject, []byte(data)); err != nil {
 		return fmt.Errorf("failed to publish to %s: %w", fullSubject, err)
 	}
@@ -251,3 +331,35 @@ func (h *SystemHealthHandler) publishEvent(subject string, event interface{}) er
 	log.Printf("📡 Published health-triggered event to %s", fullSubject)
 	return nil
 }
+
+// stringList returns a list of strings given as Go values or decoded JSON
+func stringList(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list = append(list, s)
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// number returns a number given as a Go value or decoded JSON
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/joeblew999/.github/pkg/dryrun"
+	"github.com/joeblew999/.github/pkg/embeddednats/embeddednatstest"
+	"github.com/nats-io/nats.go"
+)
+
+// collect subscribes to every subject and returns the subjects received so far
+func collect(t *testing.T, nc *nats.Conn) func() []string {
+	t.Helper()
+
+	msgs := make(chan *nats.Msg, 64)
+	sub, err := nc.ChanSubscribe(">", msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sub.Unsubscribe() })
+	if err := nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() []string {
+		if err := nc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		var subjects []string
+		timeout := time.After(500 * time.Millisecond)
+		for {
+			select {
+			case msg := <-msgs:
+				subjects = append(subjects, msg.Subject)
+			case <-timeout:
+				return subjects
+			}
+		}
+	}
+}
+
+func templateEvent(impact string, files ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"impact_level":  impact,
+		"changed_files": files,
+		"commit_sha":    "a1b2c3d",
+	}
+}
+
+func TestTemplateChangedHandlerPublishesRegeneration(t *testing.T) {
+	s := embeddednatstest.StartTestServer(t)
+	nc := embeddednatstest.Connect(t, s)
+	received := collect(t, nc)
+
+	h := NewTemplateChangedHandler(nc, "terraform", "acme", "us-east-1")
+	if err := h.Handle(context.Background(), templateEvent("IMPACT_LEVEL_LOW", "README.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := received()
+	if len(got) != 1 || got[0] != "github.regeneration_requested.acme" {
+		t.Errorf("published %v, want only github.regeneration_requested.acme", got)
+	}
+}
+
+func TestTemplateChangedHandlerScalesForWorkflowChanges(t *testing.T) {
+	terraform, err := exec.LookPath("true")
+	if err != nil {
+		t.Skip("no true binary to stand in for terraform")
+	}
+
+	s := embeddednatstest.StartTestServer(t)
+	nc := embeddednatstest.Connect(t, s)
+	received := collect(t, nc)
+
+	h := NewTemplateChangedHandler(nc, terraform, "acme", "us-east-1")
+	if err := h.Handle(context.Background(), templateEvent("IMPACT_LEVEL_MEDIUM", "workflows/ci.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		"terraform.operation.acme":           true,
+		"nats.infrastructure_scaling.acme":   true,
+		"github.regeneration_requested.acme": true,
+	}
+	got := received()
+	for _, subject := range got {
+		delete(want, subject)
+	}
+	if len(want) > 0 {
+		t.Errorf("published %v, missing %v", got, want)
+	}
+}
+
+func TestTemplateChangedHandlerDryRun(t *testing.T) {
+	s := embeddednatstest.StartTestServer(t)
+	nc := embeddednatstest.Connect(t, s)
+	received := collect(t, nc)
+
+	plan := dryrun.NewPlan()
+	h := NewTemplateChangedHandler(nc, "/nonexistent/terraform", "acme", "us-east-1")
+	h.SetPlan(plan)
+	if err := h.Handle(context.Background(), templateEvent("IMPACT_LEVEL_CRITICAL", "workflows/ci.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := received(); len(got) != 0 {
+		t.Errorf("dry run published %v", got)
+	}
+
+	kinds := make(map[string]int)
+	for _, action := range plan.Actions() {
+		kinds[action.Kind]++
+	}
+	if kinds[dryrun.KindTerraformExec] != 1 || kinds[dryrun.KindNATSPublish] != 3 {
+		t.Errorf("plan recorded %v, want 1 terraform run and 3 publishes", kinds)
+	}
+}
+
+func TestHandlersRejectMalformedEvents(t *testing.T) {
+	template := NewTemplateChangedHandler(nil, "terraform", "acme", "us-east-1")
+	health := &SystemHealthHandler{githubOrg: "acme"}
+
+	tests := []struct {
+		name    string
+		handler interface {
+			Handle(context.Context, interface{}) error
+		}
+		event interface{}
+	}{
+		{"template not a map", template, "oops"},
+		{"template without impact", template, map[string]interface{}{"changed_files": []string{"a"}, "commit_sha": "x"}},
+		{"template files not strings", template, map[string]interface{}{"impact_level": "IMPACT_LEVEL_LOW", "changed_files": []interface{}{1}, "commit_sha": "x"}},
+		{"health not a map", health, nil},
+		{"health without stats", health, map[string]interface{}{"status": "HEALTHY"}},
+		{"health queue not a number", health, map[string]interface{}{
+			"status":         "HEALTHY",
+			"event_stats":    map[string]interface{}{"events_in_queue": "many"},
+			"infrastructure": map[string]interface{}{"cpu_usage_percent": 1.0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.handler.Handle(context.Background(), tt.event); err == nil {
+				t.Error("Handle accepted a malformed event")
+			}
+		})
+	}
+}
+
+func TestHandlersAcceptDecodedJSON(t *testing.T) {
+	plan := dryrun.NewPlan()
+	template := NewTemplateChangedHandler(nil, "terraform", "acme", "us-east-1")
+	template.SetPlan(plan)
+	health := &SystemHealthHandler{githubOrg: "acme"}
+	health.SetPlan(plan)
+
+	// encoding/json decodes lists as []interface{} and numbers as float64
+	if err := template.Handle(context.Background(), map[string]interface{}{
+		"impact_level":  "IMPACT_LEVEL_LOW",
+		"changed_files": []interface{}{"README.md"},
+		"commit_sha":    "a1b2c3d",
+	}); err != nil {
+		t.Errorf("template change: %v", err)
+	}
+	if err := health.Handle(context.Background(), map[string]interface{}{
+		"status":         "DEGRADED",
+		"event_stats":    map[string]interface{}{"events_in_queue": float64(5000)},
+		"infrastructure": map[string]interface{}{"cpu_usage_percent": float64(10)},
+	}); err != nil {
+		t.Errorf("system health: %v", err)
+	}
+	if len(plan.Actions()) == 0 {
+		t.Error("no actions recorded for a loaded system")
+	}
+}
@@ -0,0 +1,12 @@
+// Package templates holds the default templates rendered by github-setup.
+// They are built into the binary; a templates directory on disk overrides
+// them file by file.
+package templates
+
+import "embed"
+
+// FS holds the default templates, rooted at this directory. Go files are
+// not templates and are skipped by github-setup.
+//
+//go:embed *
+var FS embed.FS
@@ -185,6 +185,16 @@ locals {
     "github.com/org"               = var.github_org
   }
   
+  # JetStream resources shared with nats-bootstrap
+  jetstream_spec    = yamldecode(file("${path.module}/../pkg/embeddednats/jetstream.yaml"))
+  jetstream_streams = { for stream in local.jetstream_spec.streams : stream.name => stream }
+  jetstream_consumers = merge([
+    for stream in local.jetstream_spec.streams : {
+      for consumer in try(stream.consumers, []) :
+      "${stream.name}/${consumer.name}" => merge(consumer, { stream = stream.name })
+    }
+  ]...)
+  
   # NATS configuration
   nats_config = {
     cluster_name = var.self_hosted_cluster_name
@@ -236,61 +246,59 @@ resource "nats_account" "github_org" {
   }
 }
 
-# JetStream for GitHub events
-resource "nats_stream" "github_events" {
-  name     = "GITHUB_EVENTS_${upper(var.github_org)}"
-  account  = nats_account.github_org.name
-  
-  subjects = [
-    "github.${var.github_org}.template_changed",
-    "github.${var.github_org}.workflow_status",
-    "github.${var.github_org}.regeneration_requested"
-  ]
+# JetStream streams and consumers, read from the spec nats-bootstrap provisions
+# locally (pkg/embeddednats/jetstream.yaml), so both run the same definitions
+resource "nats_stream" "jetstream" {
+  for_each = local.is_synadia_cloud ? local.jetstream_streams : {}
   
-  retention    = "workqueue"
-  max_age      = "7d"
-  max_msgs     = 1000000
-  max_bytes    = "1GB"
-  storage      = "file"
-  replicas     = 3
-  discard      = "old"
+  name    = each.value.name
+  account = nats_account.github_org[0].name
   
-  # Prevent snake tail chasing
-  duplicate_window = "2m"
+  description      = try(each.value.description, null)
+  subjects         = each.value.subjects
+  retention        = try(each.value.retention, "limits")
+  max_age          = try(each.value.max_age, null)
+  max_msgs         = try(each.value.max_msgs, null)
+  max_bytes        = try(each.value.max_bytes, null)
+  storage          = try(each.value.storage, "file")
+  replicas         = try(each.value.replicas, 3)
+  discard          = try(each.value.discard, "old")
+  duplicate_window = try(each.value.duplicate_window, null)
 }
 
-# Consumer for template change processing
-resource "nats_consumer" "template_processor" {
-  stream_name = nats_stream.github_events.name
-  account     = nats_account.github_org.name
+resource "nats_consumer" "jetstream" {
+  for_each = local.is_synadia_cloud ? local.jetstream_consumers : {}
   
-  name           = "template-processor"
-  durable        = true
-  filter_subject = "github.${var.github_org}.template_changed"
+  stream_name = nats_stream.jetstream[each.value.stream].name
+  account     = nats_account.github_org[0].name
   
-  ack_policy     = "explicit"
-  ack_wait       = "30s"
-  max_deliver    = 3
-  deliver_policy = "all"
+  name           = each.value.name
+  description    = try(each.value.description, null)
+  durable        = try(each.value.durable, false)
+  filter_subject = try(each.value.filter_subject, null)
   
-  # Rate limiting to prevent overwhelming GitHub API
-  rate_limit = 10  # messages per second
+  ack_policy     = try(each.value.ack_policy, "explicit")
+  ack_wait       = try(each.value.ack_wait, null)
+  max_deliver    = try(each.value.max_deliver, null)
+  deliver_policy = try(each.value.deliver_policy, "all")
+  rate_limit     = try(each.value.rate_limit, null)
 }
 
-# Consumer for workflow status monitoring
-resource "nats_consumer" "workflow_monitor" {
-  stream_name = nats_stream.github_events.name
-  account     = nats_account.github_org.name
-  
-  name           = "workflow-monitor"
-  durable        = true
-  filter_subject = "github.${var.github_org}.workflow_status"
-  
-  ack_policy  = "explicit"
-  max_deliver = 1
-  
-  # Deliver only new messages
-  deliver_policy = "new"
+# The per-org GITHUB_EVENTS_<ORG> work-queue stream is replaced by the
+# spec's GITHUB_EVENTS, which the controller and leafnodes read
+moved {
+  from = nats_stream.github_events
+  to   = nats_stream.jetstream["GITHUB_EVENTS"]
+}
+
+moved {
+  from = nats_consumer.template_processor
+  to   = nats_consumer.jetstream["GITHUB_EVENTS/template-processor"]
+}
+
+moved {
+  from = nats_consumer.workflow_monitor
+  to   = nats_consumer.jetstream["GITHUB_EVENTS/workflow-monitor"]
 }
 
 # AWS ECS cluster for NATS controllers
@@ -726,7 +734,8 @@ resource "kubernetes_manifest" "nats_service_monitor" {
 # Backup Resources
 # =============================================================================
 
-# CronJob for NATS JetStream backups
+# CronJob for NATS JetStream backups. The backup directories also restore into a
+# local server with `nats-bootstrap restore <dir>` or `nats-bootstrap -restore <dir>`.
 resource "kubernetes_cron_job_v1" "nats_backup" {
   count = local.is_self_hosted && var.backup_enabled && var.jetstream_enabled ? 1 : 0
   